@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// nameAllowlist is the set of IPNS names from a --names-file, a nil allowlist allows every name
+type nameAllowlist map[peer.ID]struct{}
+
+// readNamesFile reads an allowlist with one IPNS name per line, see readNamesList.
+// An empty path returns a nil allowlist.
+func readNamesFile(path string) (nameAllowlist, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	names, err := readNamesList(path)
+	if err != nil {
+		return nil, err
+	}
+	allow := make(nameAllowlist, len(names))
+	for _, name := range names {
+		allow[name] = struct{}{}
+	}
+	return allow, nil
+}
+
+// readNamesList reads one IPNS name per line in file order, blank lines and lines starting with # are ignored
+func readNamesList(path string) ([]peer.ID, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var names []peer.ID
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, err := decodeIPNSName(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		names = append(names, name)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	log.Debugw("read names file", "path", path, "names", len(names))
+	return names, nil
+}
+
+// allows returns whether records for the name should be processed
+func (a nameAllowlist) allows(name peer.ID) bool {
+	if a == nil {
+		return true
+	}
+	_, ok := a[name]
+	return ok
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-ipns"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// auditEntry is the health of the record the network serves for one name.
+// Status is valid, expired, invalid (the record fails verification), or unreachable (no record could be fetched).
+type auditEntry struct {
+	Name           string
+	Status         string
+	Value          string  `json:",omitempty"`
+	SequenceNumber *uint64 `json:",omitempty"`
+	EOL            string  `json:",omitempty"`
+	TTL            *string `json:",omitempty"`
+	Error          string  `json:",omitempty"`
+}
+
+// auditSummary counts the audited names by status
+type auditSummary struct {
+	Total       int
+	Valid       int
+	Expired     int
+	Invalid     int
+	Unreachable int
+}
+
+// auditReport is the output of audit
+type auditReport struct {
+	Summary auditSummary
+	Names   []auditEntry
+}
+
+// auditNames fetches the record for every name from the gateway with up to concurrency requests at a time, spaced out by
+// the limiter, verifies it, and outputs a report in the format, json or csv. The report is in the order of the names.
+// With csv the summary goes to stderr. Every name is checked, an error is returned at the end if any of them isn't valid.
+func auditNames(ctx context.Context, names []peer.ID, gateway string, timeout time.Duration, concurrency int, limiter *requestLimiter, format string, compact bool) error {
+	if concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1, got %d", concurrency)
+	}
+	if format != "json" && format != "csv" {
+		return fmt.Errorf("unknown format %q, may be: json or csv", format)
+	}
+	if len(names) == 0 {
+		return errors.New("the names file has no names to audit")
+	}
+
+	now := time.Now()
+	report := auditReport{Names: make([]auditEntry, len(names))}
+	pending := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pending {
+				report.Names[i] = auditName(ctx, names[i], gateway, timeout, limiter, now)
+			}
+		}()
+	}
+	log.Infow("auditing names", "names", len(names), "concurrency", concurrency)
+	for i := range names {
+		pending <- i
+	}
+	close(pending)
+	wg.Wait()
+
+	for _, e := range report.Names {
+		report.Summary.Total++
+		switch e.Status {
+		case "valid":
+			report.Summary.Valid++
+		case "expired":
+			report.Summary.Expired++
+		case "invalid":
+			report.Summary.Invalid++
+		default:
+			report.Summary.Unreachable++
+		}
+	}
+
+	if format == "csv" {
+		if err := writeAuditCSV(report.Names); err != nil {
+			return err
+		}
+		s := report.Summary
+		fmt.Fprintf(os.Stderr, "%d names: %d valid, %d expired, %d invalid, %d unreachable\n", s.Total, s.Valid, s.Expired, s.Invalid, s.Unreachable)
+	} else if err := printJSON(report, compact); err != nil {
+		return err
+	}
+
+	if s := report.Summary; s.Valid != s.Total {
+		return fmt.Errorf("%d of %d names are not healthy", s.Total-s.Valid, s.Total)
+	}
+	return nil
+}
+
+// auditName fetches and verifies the record for the name, expired records are reported as expired rather than invalid
+func auditName(ctx context.Context, name peer.ID, gateway string, timeout time.Duration, limiter *requestLimiter, now time.Time) auditEntry {
+	entry := auditEntry{Name: peer.ToCid(name).String(), Status: "unreachable"}
+
+	data, err := fetchWithBackoff(ctx, limiter, gateway, name, timeout)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+
+	entry.Status = "invalid"
+	rec, err := unmarshalIPNSRecord(data)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	if _, err := verifyIPNSRecord(name, rec, true); err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	eol, err := ipns.GetEOL(rec)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+
+	seqno := rec.GetSequence()
+	entry.Value = string(rec.GetValue())
+	entry.SequenceNumber = &seqno
+	entry.EOL = eol.Format(time.RFC3339Nano)
+	if rec.Ttl != nil {
+		ttl := time.Duration(rec.GetTtl()).String()
+		entry.TTL = &ttl
+	}
+	entry.Status = "valid"
+	if !eol.After(now) {
+		entry.Status = "expired"
+	}
+	return entry
+}
+
+// writeAuditCSV writes the audited names to stdout as CSV with a header row, the same columns as parse records followed
+// by the status and error
+func writeAuditCSV(entries []auditEntry) error {
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write(append(recordCSVHeader, "status", "error")); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		seqno, ttl := "", ""
+		if e.SequenceNumber != nil {
+			seqno = strconv.FormatUint(*e.SequenceNumber, 10)
+		}
+		if e.TTL != nil {
+			ttl = *e.TTL
+		}
+		row := []string{e.Name, e.Value, seqno, e.EOL, ttl, strconv.FormatBool(e.Status == "valid"), e.Status, e.Error}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/multiformats/go-multibase"
+
+	"github.com/urfave/cli/v2"
+)
+
+// manifestEntry describes a single record to create in a batch.
+// EOL uses the same layout as the --eol flag, Lifetime and TTL are durations such as 30m or 7d.
+type manifestEntry struct {
+	Value          string
+	SequenceNumber int64
+	EOL            string
+	Lifetime       string
+	TTL            string
+}
+
+type batchRecord struct {
+	Value          string
+	SequenceNumber int64
+	EOL            time.Time
+	TTL            *string
+	Record         string
+}
+
+// validity returns the EOL and TTL of the entry, the TTL is nil if the entry doesn't set one
+func (e manifestEntry) validity(now time.Time) (time.Time, *time.Duration, error) {
+	var ttl *time.Duration
+	if e.TTL != "" {
+		d, err := parseDuration(e.TTL)
+		if err != nil {
+			return time.Time{}, nil, err
+		}
+		ttl = &d
+	}
+
+	switch {
+	case e.EOL != "" && e.Lifetime != "":
+		return time.Time{}, nil, fmt.Errorf("cannot define lifetime and eol on a record, choose one")
+	case e.EOL != "":
+		eol, err := time.Parse("2006-01-02T15:04:05", e.EOL)
+		if err != nil {
+			return time.Time{}, nil, err
+		}
+		return eol, ttl, nil
+	case e.Lifetime != "":
+		lifetime, err := parseDuration(e.Lifetime)
+		if err != nil {
+			return time.Time{}, nil, err
+		}
+		return now.Add(lifetime), ttl, nil
+	default:
+		return now.Add(time.Hour * 24), ttl, nil
+	}
+}
+
+// manifestReader reads manifest entries one at a time so large manifests don't have to fit in memory.
+// The manifest is either a JSON array or JSON lines (one entry per line), which is detected from its first byte.
+type manifestReader struct {
+	dec   *json.Decoder
+	array bool
+}
+
+func newManifestReader(r io.Reader) (*manifestReader, error) {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("manifest is empty")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !unicode.IsSpace(rune(b[0])) {
+			break
+		}
+		if _, err := br.ReadByte(); err != nil {
+			return nil, err
+		}
+	}
+
+	m := &manifestReader{dec: json.NewDecoder(br)}
+	if b, _ := br.Peek(1); b[0] == '[' {
+		m.array = true
+		if _, err := m.dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// next returns the next entry in the manifest, or io.EOF once every entry has been read
+func (m *manifestReader) next() (manifestEntry, error) {
+	var e manifestEntry
+	if m.array && !m.dec.More() {
+		if _, err := m.dec.Token(); err != nil {
+			return e, err
+		}
+		return e, io.EOF
+	}
+	err := m.dec.Decode(&e)
+	return e, err
+}
+
+// batchJob is a manifest entry being signed, the result is sent on done
+type batchJob struct {
+	index int
+	entry manifestEntry
+	done  chan batchResult
+}
+
+type batchResult struct {
+	record batchRecord
+	err    error
+}
+
+// batchWriter writes the batch output incrementally, as a JSON array or as JSON lines
+type batchWriter struct {
+	w     *bufio.Writer
+	lines bool
+	count int
+}
+
+func (b *batchWriter) write(r batchRecord) error {
+	if b.lines {
+		out, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		b.count++
+		_, err = fmt.Fprintf(b.w, "%s\n", out)
+		return err
+	}
+
+	// Matches the output of printJSON on the whole array
+	out, err := json.MarshalIndent(r, "    ", "    ")
+	if err != nil {
+		return err
+	}
+	sep := ",\n    "
+	if b.count == 0 {
+		sep = "[\n    "
+	}
+	b.count++
+	_, err = fmt.Fprintf(b.w, "%s%s", sep, out)
+	return err
+}
+
+func (b *batchWriter) close() error {
+	if !b.lines {
+		end := "\n]\n"
+		if b.count == 0 {
+			end = "[]\n"
+		}
+		if _, err := b.w.WriteString(end); err != nil {
+			return err
+		}
+	}
+	return b.w.Flush()
+}
+
+// progressInterval is how often --progress reports how many records have been signed
+const progressInterval = 5 * time.Second
+
+// createIPNSRecords signs every entry in the manifest using up to concurrency workers.
+// The manifest is streamed and records are written as they are signed, a JSON array manifest gives a JSON array
+// and a JSON lines manifest gives JSON lines. The output is in the same order as the manifest regardless of the concurrency.
+// The batch stops at the first entry that fails, records before it have already been written.
+func createIPNSRecords(manifestPath string, privKey crypto.PrivKey, outputBase string, concurrency int, progress bool) error {
+	if concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1, got %d", concurrency)
+	}
+
+	f, err := os.Open(manifestPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	manifest, err := newManifestReader(f)
+	if err != nil {
+		return err
+	}
+
+	enc, err := multibase.EncoderByName(outputBase)
+	if err != nil {
+		return err
+	}
+
+	// Each worker gets its own copy of the key so no key state is shared between goroutines
+	keyBytes, err := crypto.MarshalPrivateKey(privKey)
+	if err != nil {
+		return err
+	}
+
+	log.Infow("signing records", "concurrency", concurrency, "jsonl", !manifest.array)
+	now := time.Now()
+	jobs := make(chan batchJob)
+	// pending holds the jobs in manifest order, its buffer bounds how far signing can run ahead of writing
+	pending := make(chan batchJob, 4*concurrency)
+	stop := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		workerKey, err := crypto.UnmarshalPrivateKey(keyBytes)
+		if err != nil {
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				rec, err := signBatchEntry(j.entry, now, workerKey, enc)
+				j.done <- batchResult{record: rec, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+		for i := 0; ; i++ {
+			e, err := manifest.next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+
+			j := batchJob{index: i, entry: e, done: make(chan batchResult, 1)}
+			if err != nil {
+				j.done <- batchResult{err: err}
+				select {
+				case pending <- j:
+				case <-stop:
+				}
+				return
+			}
+
+			select {
+			case pending <- j:
+			case <-stop:
+				return
+			}
+			select {
+			case jobs <- j:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	out := &batchWriter{w: bufio.NewWriter(os.Stdout), lines: !manifest.array}
+	start, lastReport := time.Now(), time.Now()
+	for j := range pending {
+		res := <-j.done
+		if res.err == nil {
+			res.err = out.write(res.record)
+		}
+		if res.err != nil {
+			close(stop)
+			wg.Wait()
+			if err := out.w.Flush(); err != nil {
+				return err
+			}
+			return fmt.Errorf("manifest entry %d: %w", j.index, res.err)
+		}
+
+		if progress && time.Since(lastReport) >= progressInterval {
+			lastReport = time.Now()
+			fmt.Fprintf(os.Stderr, "signed %d records (%.0f/s)\n", out.count, float64(out.count)/time.Since(start).Seconds())
+		}
+	}
+	wg.Wait()
+
+	if progress {
+		fmt.Fprintf(os.Stderr, "signed %d records in %s\n", out.count, time.Since(start).Round(time.Millisecond))
+	}
+	return out.close()
+}
+
+// signBatchEntry signs the record for a single manifest entry
+func signBatchEntry(e manifestEntry, now time.Time, privKey crypto.PrivKey, enc multibase.Encoder) (batchRecord, error) {
+	eol, ttl, err := e.validity(now)
+	if err != nil {
+		return batchRecord{}, err
+	}
+
+	recBytes, err := signIPNSRecord(e.SequenceNumber, ttl, eol, e.Value, nil, privKey)
+	if err != nil {
+		return batchRecord{}, err
+	}
+
+	var ttlString *string
+	if ttl != nil {
+		s := ttl.String()
+		ttlString = &s
+	}
+	return batchRecord{
+		Value:          e.Value,
+		SequenceNumber: e.SequenceNumber,
+		EOL:            eol.UTC(),
+		TTL:            ttlString,
+		Record:         enc.Encode(recBytes),
+	}, nil
+}
+
+// fileRecord is a parsed record along with the file it was read from, data is the record as read for checking its validity
+type fileRecord struct {
+	File   string
+	Record *parsedRecord
+
+	data []byte
+}
+
+// sortFileRecords sorts parsed records by name (the file name, which is usually the IPNS name), seqno, or eol.
+// Ties keep file name order.
+func sortFileRecords(results []fileRecord, by string) error {
+	var less func(a, b fileRecord) bool
+	switch by {
+	case "name":
+		less = func(a, b fileRecord) bool { return a.File < b.File }
+	case "seqno":
+		less = func(a, b fileRecord) bool { return a.Record.SequenceNumber < b.Record.SequenceNumber }
+	case "eol":
+		less = func(a, b fileRecord) bool { return a.Record.eol.Before(b.Record.eol) }
+	default:
+		return fmt.Errorf("cannot sort records by %q, may be: name, seqno, or eol", by)
+	}
+	sort.SliceStable(results, func(i, j int) bool { return less(results[i], results[j]) })
+	return nil
+}
+
+// failFastMode returns whether a batch command should stop at the first failure based on its --fail-fast and --continue-on-error flags
+func failFastMode(c *cli.Context) (bool, error) {
+	if c.Bool("fail-fast") && c.IsSet("continue-on-error") {
+		return false, errors.New("cannot use --fail-fast and --continue-on-error together, choose one")
+	}
+	return c.Bool("fail-fast") || !c.Bool("continue-on-error"), nil
+}
+
+// processDirectory calls process on every file in dir, in lexical order.
+// Unless failFast is set every file is processed and a summary of the failures is written to stderr.
+func processDirectory(dir string, failFast bool, process func(path string) error) error {
+	if dir == "" {
+		return errors.New("no directory specified")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var total int
+	var failures []error
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		total++
+
+		path := filepath.Join(dir, e.Name())
+		log.Debugw("processing file", "path", path)
+		if err := process(path); err != nil {
+			err = fmt.Errorf("%s: %w", path, err)
+			if failFast {
+				return err
+			}
+			failures = append(failures, err)
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "%d of %d records failed:\n", len(failures), total)
+	for _, err := range failures {
+		fmt.Fprintf(os.Stderr, "  %v\n", err)
+	}
+	return fmt.Errorf("%d of %d records failed", len(failures), total)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestManifestReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+	}{
+		{"array", `[{"Value": "/ipfs/a", "SequenceNumber": 1}, {"Value": "/ipfs/b", "TTL": "1m"}]`},
+		{"array with whitespace", "\n\t [\n{\"Value\": \"/ipfs/a\", \"SequenceNumber\": 1},\n{\"Value\": \"/ipfs/b\", \"TTL\": \"1m\"}\n]\n"},
+		{"json lines", "{\"Value\": \"/ipfs/a\", \"SequenceNumber\": 1}\n{\"Value\": \"/ipfs/b\", \"TTL\": \"1m\"}\n"},
+		{"json lines without trailing newline", "  {\"Value\": \"/ipfs/a\", \"SequenceNumber\": 1}\n{\"Value\": \"/ipfs/b\", \"TTL\": \"1m\"}"},
+	}
+	want := []manifestEntry{
+		{Value: "/ipfs/a", SequenceNumber: 1},
+		{Value: "/ipfs/b", TTL: "1m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newManifestReader(strings.NewReader(tt.manifest))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []manifestEntry
+			for {
+				e, err := m.next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, e)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("got %d entries, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("entry %d is %+v, want %+v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestManifestReaderEmpty(t *testing.T) {
+	for _, manifest := range []string{"", " \n\t"} {
+		if _, err := newManifestReader(strings.NewReader(manifest)); err == nil {
+			t.Errorf("expected an error for manifest %q", manifest)
+		}
+	}
+
+	// an empty array is a valid manifest without entries
+	m, err := newManifestReader(strings.NewReader("[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("got %v, want io.EOF", err)
+	}
+}
+
+func TestManifestReaderInvalidEntry(t *testing.T) {
+	for _, manifest := range []string{`[{"Value": "/ipfs/a"}, 5]`, "{\"Value\": \"/ipfs/a\"}\nnot json\n"} {
+		m, err := newManifestReader(strings.NewReader(manifest))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.next(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.next(); err == nil || errors.Is(err, io.EOF) {
+			t.Errorf("got %v for an invalid second entry in %q", err, manifest)
+		}
+	}
+}
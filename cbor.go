@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+	ipld "github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	"github.com/libp2p/go-libp2p-core/crypto"
+)
+
+// reservedCBORFields are the standard fields of the CBOR data in a V2 record
+var reservedCBORFields = map[string]bool{
+	"Value":        true,
+	"Validity":     true,
+	"ValidityType": true,
+	"Sequence":     true,
+	"TTL":          true,
+}
+
+// parseExtraFields parses key=value pairs into extra CBOR fields, refusing the standard field names
+func parseExtraFields(fields []string) (map[string]string, error) {
+	extra := make(map[string]string)
+	for _, f := range fields {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("extra field %q is not in the form key=value", f)
+		}
+		k, v := kv[0], kv[1]
+		if reservedCBORFields[k] {
+			return nil, fmt.Errorf("extra field %q collides with a standard IPNS record field", k)
+		}
+		if _, ok := extra[k]; ok {
+			return nil, fmt.Errorf("extra field %q is set more than once", k)
+		}
+		extra[k] = v
+	}
+	return extra, nil
+}
+
+func decodeCBORData(data []byte) (ipld.Node, error) {
+	nb := basicnode.Prototype.Map.NewBuilder()
+	if err := dagcbor.Decode(nb, bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("could not decode the record's CBOR data: %w", err)
+	}
+	return nb.Build(), nil
+}
+
+// addExtraCBORFields adds the extra fields to the record's CBOR data as strings and signs the new data.
+// The V1 signature doesn't cover the CBOR data so it stays valid.
+func addExtraCBORFields(rec *ipns_pb.IpnsEntry, extra map[string]string, priv crypto.PrivKey) error {
+	if len(extra) == 0 {
+		return nil
+	}
+
+	nd, err := decodeCBORData(rec.GetData())
+	if err != nil {
+		return err
+	}
+
+	fields := make(map[string]ipld.Node)
+	it := nd.MapIterator()
+	for !it.Done() {
+		k, v, err := it.Next()
+		if err != nil {
+			return err
+		}
+		ks, err := k.AsString()
+		if err != nil {
+			return err
+		}
+		fields[ks] = v
+	}
+	for k, v := range extra {
+		fields[k] = basicnode.NewString(v)
+	}
+
+	// DAG-CBOR map keys are sorted by length and then bytewise, the same as go-ipns does for the standard fields
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sortedCBORKeys(keys)
+
+	nb := basicnode.Prototype.Map.NewBuilder()
+	ma, err := nb.BeginMap(int64(len(keys)))
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := ma.AssembleKey().AssignString(k); err != nil {
+			return err
+		}
+		if err := ma.AssembleValue().AssignNode(fields[k]); err != nil {
+			return err
+		}
+	}
+	if err := ma.Finish(); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := dagcbor.Encode(nb.Build(), &buf); err != nil {
+		return err
+	}
+	rec.Data = buf.Bytes()
+
+	sig, err := priv.Sign(append([]byte(ipnsSignatureV2Prefix), rec.Data...))
+	if err != nil {
+		return err
+	}
+	rec.SignatureV2 = sig
+	return nil
+}
+
+// extraCBORFields returns the non-standard fields in the record's CBOR data as DAG-JSON
+func extraCBORFields(data []byte) (map[string]json.RawMessage, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	nd, err := decodeCBORData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var extra map[string]json.RawMessage
+	it := nd.MapIterator()
+	for !it.Done() {
+		k, v, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		ks, err := k.AsString()
+		if err != nil {
+			return nil, err
+		}
+		if reservedCBORFields[ks] {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := dagjson.Encode(v, &buf); err != nil {
+			return nil, err
+		}
+		if extra == nil {
+			extra = make(map[string]json.RawMessage)
+		}
+		extra[ks] = buf.Bytes()
+	}
+	return extra, nil
+}
+
+// cborDataTTL returns the TTL in the record's CBOR data, or nil when the record has no CBOR data or it has no TTL
+func cborDataTTL(data []byte) (*time.Duration, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	nd, err := decodeCBORData(data)
+	if err != nil {
+		return nil, err
+	}
+	v, err := nd.LookupByString("TTL")
+	if err != nil {
+		return nil, nil
+	}
+	n, err := v.AsInt()
+	if err != nil {
+		return nil, fmt.Errorf("the TTL in the record's CBOR data is not an integer: %w", err)
+	}
+	ttl := time.Duration(n)
+	return &ttl, nil
+}
+
+// standardCBORData builds the CBOR data of a V2 record from its protobuf fields, the same way go-ipns does
+func standardCBORData(rec *ipns_pb.IpnsEntry) ([]byte, error) {
+	// keys in DAG-CBOR order, shortest first
+	keys := []string{"TTL", "Value", "Sequence", "Validity", "ValidityType"}
+	fields := map[string]ipld.Node{
+		"TTL":          basicnode.NewInt(int64(rec.GetTtl())),
+		"Value":        basicnode.NewBytes(rec.GetValue()),
+		"Sequence":     basicnode.NewInt(int64(rec.GetSequence())),
+		"Validity":     basicnode.NewBytes(rec.GetValidity()),
+		"ValidityType": basicnode.NewInt(int64(rec.GetValidityType())),
+	}
+
+	nb := basicnode.Prototype.Map.NewBuilder()
+	ma, err := nb.BeginMap(int64(len(keys)))
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if err := ma.AssembleKey().AssignString(k); err != nil {
+			return nil, err
+		}
+		if err := ma.AssembleValue().AssignNode(fields[k]); err != nil {
+			return nil, err
+		}
+	}
+	if err := ma.Finish(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := dagcbor.Encode(nb.Build(), &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sortedCBORKeys sorts map keys in DAG-CBOR order, by length and then bytewise
+func sortedCBORKeys(keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) < len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+}
+
+// canonicalCBORNode rebuilds the node with the keys of every map, however deeply nested, in DAG-CBOR order
+func canonicalCBORNode(nd ipld.Node) (ipld.Node, error) {
+	switch nd.Kind() {
+	case ipld.Kind_Map:
+		fields := make(map[string]ipld.Node)
+		keys := make([]string, 0, nd.Length())
+		it := nd.MapIterator()
+		for !it.Done() {
+			k, v, err := it.Next()
+			if err != nil {
+				return nil, err
+			}
+			ks, err := k.AsString()
+			if err != nil {
+				return nil, err
+			}
+			if fields[ks], err = canonicalCBORNode(v); err != nil {
+				return nil, err
+			}
+			keys = append(keys, ks)
+		}
+		sortedCBORKeys(keys)
+
+		nb := basicnode.Prototype.Map.NewBuilder()
+		ma, err := nb.BeginMap(int64(len(keys)))
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			if err := ma.AssembleKey().AssignString(k); err != nil {
+				return nil, err
+			}
+			if err := ma.AssembleValue().AssignNode(fields[k]); err != nil {
+				return nil, err
+			}
+		}
+		if err := ma.Finish(); err != nil {
+			return nil, err
+		}
+		return nb.Build(), nil
+	case ipld.Kind_List:
+		nb := basicnode.Prototype.List.NewBuilder()
+		la, err := nb.BeginList(nd.Length())
+		if err != nil {
+			return nil, err
+		}
+		it := nd.ListIterator()
+		for !it.Done() {
+			_, v, err := it.Next()
+			if err != nil {
+				return nil, err
+			}
+			cv, err := canonicalCBORNode(v)
+			if err != nil {
+				return nil, err
+			}
+			if err := la.AssembleValue().AssignNode(cv); err != nil {
+				return nil, err
+			}
+		}
+		if err := la.Finish(); err != nil {
+			return nil, err
+		}
+		return nb.Build(), nil
+	default:
+		return nd, nil
+	}
+}
+
+// checkCanonicalCBOR confirms the record's CBOR data is canonical DAG-CBOR by decoding it, re-encoding it with
+// sorted map keys and minimal lengths, and comparing the bytes. Implementations that re-encode the data before
+// checking the V2 signature reject records that aren't canonical. Records without CBOR data pass.
+func checkCanonicalCBOR(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	nd, err := decodeCBORData(data)
+	if err != nil {
+		return err
+	}
+	canonical, err := canonicalCBORNode(nd)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := dagcbor.Encode(canonical, &buf); err != nil {
+		return err
+	}
+
+	if bytes.Equal(buf.Bytes(), data) {
+		return nil
+	}
+	offset := 0
+	for offset < len(data) && offset < buf.Len() && data[offset] == buf.Bytes()[offset] {
+		offset++
+	}
+	return fmt.Errorf("the record's CBOR data is not canonical DAG-CBOR, it first differs from the canonical encoding at byte %d (%d bytes, canonical is %d bytes)", offset, len(data), buf.Len())
+}
+
+// recordFromDAGCBOR returns the bytes at the path inside a DAG-CBOR object, for records stored as a field of a larger object.
+// The path is / separated map keys and list indexes, e.g. names/0/record, an empty path is the object itself.
+func recordFromDAGCBOR(data []byte, path string) ([]byte, error) {
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err := dagcbor.Decode(nb, bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("could not decode the DAG-CBOR object: %w", err)
+	}
+	nd := nb.Build()
+
+	var walked []string
+	for _, seg := range strings.Split(strings.Trim(path, "/"), "/") {
+		if seg == "" {
+			continue
+		}
+		at := "/" + strings.Join(walked, "/")
+		var next ipld.Node
+		var err error
+		switch nd.Kind() {
+		case ipld.Kind_Map:
+			if next, err = nd.LookupByString(seg); err != nil {
+				return nil, fmt.Errorf("%s has no key %q", at, seg)
+			}
+		case ipld.Kind_List:
+			i, convErr := strconv.ParseInt(seg, 10, 64)
+			if convErr != nil {
+				return nil, fmt.Errorf("%s is a list, %q is not an index", at, seg)
+			}
+			if next, err = nd.LookupByIndex(i); err != nil {
+				return nil, fmt.Errorf("%s is a list of %d, there is no index %d", at, nd.Length(), i)
+			}
+		default:
+			return nil, fmt.Errorf("cannot look up %q in %s, it is a %s", seg, at, nd.Kind())
+		}
+		nd = next
+		walked = append(walked, seg)
+	}
+
+	rec, err := nd.AsBytes()
+	if err != nil {
+		return nil, fmt.Errorf("the value at /%s is a %s, not the bytes of a record", strings.Join(walked, "/"), nd.Kind())
+	}
+	return rec, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-ipns"
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+	"github.com/libp2p/go-libp2p-core/crypto"
+)
+
+func TestParseExtraFields(t *testing.T) {
+	got, err := parseExtraFields([]string{"app=blog", "note=a=b", "empty="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"app": "blog", "note": "a=b", "empty": ""}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s is %q, want %q", k, got[k], v)
+		}
+	}
+
+	for _, bad := range [][]string{{"novalue"}, {"=x"}, {"TTL=1"}, {"Value=x"}, {"a=1", "a=2"}} {
+		if _, err := parseExtraFields(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestExtraCBORFieldsRoundTrip(t *testing.T) {
+	priv, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extra := map[string]string{"app": "blog", "a": "1"}
+	recBytes, err := signIPNSRecord(1, nil, time.Now().Add(time.Hour), "/ipfs/bafkqaaa", extra, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := unmarshalIPNSRecord(recBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the data was re-signed, so the V2 signature must still verify
+	if err := ipns.Validate(priv.GetPublic(), rec); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkCanonicalCBOR(rec.GetData()); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := extraCBORFields(rec.GetData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(extra) {
+		t.Fatalf("got extra fields %v, want %v", got, extra)
+	}
+	for k, v := range extra {
+		var s string
+		if err := json.Unmarshal(got[k], &s); err != nil {
+			t.Fatal(err)
+		}
+		if s != v {
+			t.Errorf("%s is %q, want %q", k, s, v)
+		}
+	}
+}
+
+func TestExtraCBORFieldsStandardRecord(t *testing.T) {
+	priv, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recBytes, err := signIPNSRecord(1, nil, time.Now().Add(time.Hour), "/ipfs/bafkqaaa", nil, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := unmarshalIPNSRecord(recBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := extraCBORFields(rec.GetData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Fatalf("got extra fields %v for a record without any", got)
+	}
+	if got, err := extraCBORFields(nil); err != nil || got != nil {
+		t.Fatalf("got %v, %v for a record without CBOR data", got, err)
+	}
+}
+
+func TestSortedCBORKeys(t *testing.T) {
+	keys := []string{"Validity", "TTL", "b", "Value", "aa", "a", "Sequence", "ValidityType"}
+	sortedCBORKeys(keys)
+	want := []string{"a", "b", "aa", "TTL", "Value", "Sequence", "Validity", "ValidityType"}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Fatalf("got %v, want %v", keys, want)
+		}
+	}
+}
+
+func TestCheckCanonicalCBOR(t *testing.T) {
+	ttl := uint64(time.Hour)
+	seqno := uint64(3)
+	rec := &ipns_pb.IpnsEntry{Value: []byte("/ipfs/bafkqaaa"), Validity: []byte("2030-01-01T00:00:00Z"), Sequence: &seqno, Ttl: &ttl}
+	standard, err := standardCBORData(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		data      []byte
+		canonical bool
+	}{
+		{"no data", nil, true},
+		{"standard fields", standard, true},
+		// {"a": 2, "bb": 1}
+		{"sorted keys", []byte{0xa2, 0x61, 'a', 0x02, 0x62, 'b', 'b', 0x01}, true},
+		// {"bb": 1, "a": 2}, longer key first
+		{"unsorted keys", []byte{0xa2, 0x62, 'b', 'b', 0x01, 0x61, 'a', 0x02}, false},
+		// {"a": {"bb": 1, "a": 2}}, unsorted only in the nested map
+		{"unsorted nested keys", []byte{0xa1, 0x61, 'a', 0xa2, 0x62, 'b', 'b', 0x01, 0x61, 'a', 0x02}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCanonicalCBOR(tt.data)
+			if tt.canonical && err != nil {
+				t.Fatal(err)
+			}
+			if !tt.canonical && err == nil {
+				t.Fatal("expected an error for data that isn't canonical")
+			}
+		})
+	}
+}
+
+func TestCBORDataTTL(t *testing.T) {
+	priv, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hour := time.Hour
+	for _, ttl := range []*time.Duration{nil, &hour} {
+		recBytes, err := signIPNSRecord(1, ttl, time.Now().Add(time.Hour), "/ipfs/bafkqaaa", nil, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec, err := unmarshalIPNSRecord(recBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := cborDataTTL(rec.GetData())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a TTL left out of the protobuf is still signed as 0 in the CBOR data
+		want := time.Duration(0)
+		if ttl != nil {
+			want = *ttl
+		}
+		if got == nil || *got != want {
+			t.Fatalf("got CBOR TTL %v, want %v", got, want)
+		}
+		if (rec.Ttl == nil) != (ttl == nil) {
+			t.Fatalf("protobuf TTL is %v for --ttl %v", rec.Ttl, ttl)
+		}
+	}
+
+	if got, err := cborDataTTL(nil); err != nil || got != nil {
+		t.Fatalf("got %v, %v for a record without CBOR data", got, err)
+	}
+}
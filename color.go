@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/term"
+)
+
+// ANSI colors for the categories of fields in table output
+const (
+	colorReset     = "\x1b[0m"
+	colorLabel     = "\x1b[1m"
+	colorValue     = "\x1b[32m"
+	colorTimestamp = "\x1b[36m"
+	colorNumber    = "\x1b[33m"
+	colorKey       = "\x1b[35m"
+)
+
+// colorEnabled is whether table output is colored, set by setupColor from --color
+var colorEnabled bool
+
+// setupColor sets whether table output is colored. mode may be auto, always, or never, auto colors when stdout is
+// a terminal unless NO_COLOR is set or TERM is dumb. --no-color is the same as --color never.
+func setupColor(mode string, noColor bool) error {
+	if noColor {
+		mode = "never"
+	}
+	switch mode {
+	case "auto":
+		colorEnabled = term.IsTerminal(int(os.Stdout.Fd())) && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb"
+	case "always":
+		colorEnabled = true
+	case "never":
+		colorEnabled = false
+	default:
+		return fmt.Errorf("unknown --color %q, may be: auto, always, or never", mode)
+	}
+	return nil
+}
+
+// colorize wraps s in the color when color is enabled.
+// tabwriter counts the escape codes as width, so every cell of a column should get the same color, or the column should be the last.
+func colorize(s, color string) string {
+	if !colorEnabled {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// tableRow is a label and value in a key/value table, colored by the category of the value
+type tableRow struct {
+	label, value, color string
+}
+
+// writeKeyValueTable writes the rows as an aligned two column table with bold labels and colored values
+func writeKeyValueTable(out io.Writer, rows []tableRow) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, r := range rows {
+		fmt.Fprintf(w, "%s\t%s\n", colorize(r.label, colorLabel), colorize(r.value, r.color))
+	}
+	return w.Flush()
+}
+
+// printRecordTable prints the parsed record as a key/value table, see writeKeyValueTable
+func printRecordTable(rec *parsedRecord) error {
+	ttl := "none"
+	if rec.TTL != nil {
+		ttl = *rec.TTL
+	}
+	rows := []tableRow{
+		{"value", rec.Value, colorValue},
+	}
+	if rec.Path.CID != "" {
+		rows = append(rows, tableRow{"cid", rec.Path.CID, colorValue})
+	}
+	rows = append(rows,
+		tableRow{"seqno", fmt.Sprint(rec.SequenceNumber), colorNumber},
+		tableRow{"eol", rec.EOL, colorTimestamp},
+	)
+	if rec.EOLUnix != nil {
+		rows = append(rows,
+			tableRow{"eol rfc3339", rec.EOLRFC3339, colorTimestamp},
+			tableRow{"eol unix", fmt.Sprint(*rec.EOLUnix), colorTimestamp},
+		)
+	}
+	rows = append(rows, tableRow{"ttl", ttl, colorTimestamp})
+	if rec.PubKey != "" {
+		rows = append(rows, tableRow{"pubkey", rec.PubKey, colorKey})
+	}
+	if rec.Shape != nil {
+		rows = append(rows,
+			tableRow{"fields", strings.Join(rec.Shape.Fields, ", "), colorKey},
+			tableRow{"signatures", rec.Shape.Signatures, colorKey},
+		)
+	}
+	if rec.Publisher != nil {
+		rows = append(rows, tableRow{"publisher", fmt.Sprintf("%s (%s confidence)", rec.Publisher.Publisher, rec.Publisher.Confidence), colorValue})
+	}
+
+	extra := make([]string, 0, len(rec.ExtraFields))
+	for k := range rec.ExtraFields {
+		extra = append(extra, k)
+	}
+	sort.Strings(extra)
+	for _, k := range extra {
+		rows = append(rows, tableRow{"extra " + k, string(rec.ExtraFields[k]), colorValue})
+	}
+	return writeKeyValueTable(os.Stdout, rows)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/urfave/cli/v2"
+)
+
+const defaultConfigPath = "~/.config/ipns-utils/config.yaml"
+
+// readConfig reads the flag defaults from a YAML config file.
+// Top level keys are flag names used by every command with that flag, and keys with a full command name
+// (e.g. "create record") hold flag defaults for just that command.
+// A missing file is only an error when the path was passed explicitly.
+func readConfig(path string, explicit bool) (map[string]interface{}, error) {
+	path, err := expandHome(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	config := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("could not parse config file %s: %w", path, err)
+	}
+	log.Debugw("read config file", "path", path)
+	return config, nil
+}
+
+// conflictingFlags are the flags that can't be used together. A config value gives way to a flag passed on the
+// command line that it conflicts with, so the command line always wins, and the base shortcuts count as --output-base.
+var conflictingFlags = map[string][]string{
+	"eol":              {"lifetime"},
+	"ttl":              {"ttl-from-eol"},
+	"minimal":          {"maximal", "ttl", "ttl-from-eol", "extra-field"},
+	"from-add":         {"value", "interactive"},
+	"validity-type":    {"extra-field"},
+	"sign-only":        signOnlyConflicts,
+	"http-response":    {"output-base"},
+	"pkcs11-module":    {"key-file", "key-encoded", "key-env"},
+	"key-file":         {"key-encoded", "key-env"},
+	"key-encoded":      {"key-env"},
+	"key-password":     {"key-password-env"},
+	"shares":           {"stdout", "stderr", "output-base", "kubo-import"},
+	"threshold":        {"stdout", "stderr", "output-base", "kubo-import"},
+	"reconstruct-args": {"env", "table", "compact", "redact"},
+	"env":              {"table", "compact"},
+	"table":            {"compact"},
+	"fail-fast":        {"continue-on-error"},
+	"repeat":           {"dry-run"},
+	"uri":              {"qr", "qr-out"},
+}
+
+// conflictName is the name a flag has in conflictingFlags
+func conflictName(name string) string {
+	for _, b := range baseShortcuts {
+		if name == b {
+			return "output-base"
+		}
+	}
+	return name
+}
+
+// conflictsWith returns the first of others that can't be used together with the flag, or "" if there is none
+func conflictsWith(name string, others []string) string {
+	a := conflictName(name)
+	for _, other := range others {
+		b := conflictName(other)
+		if a == b {
+			return other
+		}
+		for _, f := range conflictingFlags[a] {
+			if f == b {
+				return other
+			}
+		}
+		for _, f := range conflictingFlags[b] {
+			if f == a {
+				return other
+			}
+		}
+	}
+	return ""
+}
+
+// checkConfig returns an error for config keys that aren't a flag. commands has the flag names of every command
+// by its full name, a section must be one of them and top level keys must be a flag of at least one.
+func checkConfig(config map[string]interface{}, commands map[string][]string) error {
+	anyFlag := make(map[string]bool)
+	for _, flags := range commands {
+		for _, f := range flags {
+			anyFlag[f] = true
+		}
+	}
+
+	for k, v := range config {
+		section, ok := v.(map[interface{}]interface{})
+		if !ok {
+			if !anyFlag[k] {
+				return fmt.Errorf("unknown flag %q in the config file, no command has it", k)
+			}
+			continue
+		}
+		flags, ok := commands[k]
+		if !ok {
+			return fmt.Errorf("unknown command %q in the config file", k)
+		}
+		for f := range section {
+			if !containsString(flags, fmt.Sprint(f)) {
+				return fmt.Errorf("unknown flag %q for %q in the config file", f, k)
+			}
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// applyConfig sets the flags of the running command from the config file, after the command line has been parsed,
+// so the rest of the command sees config values as if they were passed on the command line.
+// The command line wins over the command's section, which wins over the top level keys. A value is skipped when
+// a flag that wins over it is set or it can't be used with one (see conflictingFlags), two values at the same
+// level that can't be used together are an error.
+func applyConfig(c *cli.Context, fullName string, commands map[string][]string) error {
+	config, err := readConfig(c.String("config"), c.IsSet("config"))
+	if err != nil || config == nil {
+		return err
+	}
+	if err := checkConfig(config, commands); err != nil {
+		return err
+	}
+
+	top := make(map[string]interface{})
+	for k, v := range config {
+		if _, ok := v.(map[interface{}]interface{}); !ok {
+			top[k] = v
+		}
+	}
+	section := make(map[string]interface{})
+	if s, ok := config[fullName].(map[interface{}]interface{}); ok {
+		for k, v := range s {
+			section[fmt.Sprint(k)] = v
+		}
+	}
+
+	var given []string
+	for _, name := range commands[fullName] {
+		if c.IsSet(name) {
+			given = append(given, name)
+		}
+	}
+	for _, level := range []map[string]interface{}{section, top} {
+		var set []string
+		for _, name := range commands[fullName] {
+			v, ok := level[name]
+			if !ok {
+				continue
+			}
+			if other := conflictsWith(name, given); other != "" {
+				log.Debugw("config value gives way to a flag", "command", fullName, "flag", name, "overridden by", other)
+				continue
+			}
+			if other := conflictsWith(name, set); other != "" {
+				return fmt.Errorf("the config file sets both %s and %s for %q, which cannot be used together", other, name, fullName)
+			}
+			log.Debugw("setting flag from config", "command", fullName, "flag", name, "value", v)
+			if err := setFlagFromConfig(c, name, v); err != nil {
+				return fmt.Errorf("invalid config value for %s: %w", name, err)
+			}
+			set = append(set, name)
+		}
+		given = append(given, set...)
+	}
+	return nil
+}
+
+// setFlagFromConfig sets the flag to a config value, a list sets a flag that may be repeated once per item
+func setFlagFromConfig(c *cli.Context, name string, v interface{}) error {
+	items, ok := v.([]interface{})
+	if !ok {
+		return c.Set(name, fmt.Sprint(v))
+	}
+	if _, ok := c.Value(name).(cli.StringSlice); !ok {
+		return fmt.Errorf("%s takes a single value, not a list", name)
+	}
+	for _, item := range items {
+		if err := c.Set(name, fmt.Sprint(item)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setConfigDefaults makes every command with an action apply the config file before its own Before runs
+func setConfigDefaults(app *cli.App) {
+	commands := make(map[string][]string)
+	var walk func(prefix string, cmds []*cli.Command, apply func(fullName string, cmd *cli.Command))
+	walk = func(prefix string, cmds []*cli.Command, apply func(fullName string, cmd *cli.Command)) {
+		for _, cmd := range cmds {
+			fullName := strings.TrimSpace(prefix + " " + cmd.Name)
+			if cmd.Action != nil && len(cmd.Subcommands) == 0 {
+				apply(fullName, cmd)
+			}
+			walk(fullName, cmd.Subcommands, apply)
+		}
+	}
+
+	walk("", app.Commands, func(fullName string, cmd *cli.Command) {
+		for _, f := range cmd.Flags {
+			commands[fullName] = append(commands[fullName], f.Names()[0])
+		}
+	})
+	walk("", app.Commands, func(fullName string, cmd *cli.Command) {
+		before := cmd.Before
+		cmd.Before = func(c *cli.Context) error {
+			if err := applyConfig(c, fullName, commands); err != nil {
+				return err
+			}
+			if before != nil {
+				return before(c)
+			}
+			return nil
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+// runWithConfig runs a create record command with a few of the real flags and returns the flags that ended up set
+func runWithConfig(t *testing.T, config string, args ...string) (map[string]string, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]string)
+	app := &cli.App{
+		Flags: []cli.Flag{&cli.PathFlag{Name: "config"}},
+		Commands: []*cli.Command{{
+			Name: "create",
+			Subcommands: []*cli.Command{{
+				Name: "record",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "lifetime"},
+					&cli.StringFlag{Name: "eol"},
+					&cli.StringFlag{Name: "ttl"},
+					&cli.BoolFlag{Name: "ttl-from-eol"},
+					&cli.StringFlag{Name: "output-base"},
+					&cli.StringSliceFlag{Name: "extra-field"},
+				},
+				Action: func(c *cli.Context) error {
+					for _, f := range c.Command.Flags {
+						name := f.Names()[0]
+						if !c.IsSet(name) {
+							continue
+						}
+						switch f.(type) {
+						case *cli.StringSliceFlag:
+							got[name] = strings.Join(c.StringSlice(name), ",")
+						case *cli.BoolFlag:
+							got[name] = strconv.FormatBool(c.Bool(name))
+						default:
+							got[name] = c.String(name)
+						}
+					}
+					return nil
+				},
+			}},
+		}},
+	}
+	addBaseShortcutFlags(app.Commands)
+	setConfigDefaults(app)
+	err := app.Run(append([]string{"ipns-utils", "--config", path, "create", "record"}, args...))
+	return got, err
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	config := `
+lifetime: 1h
+output-base: base36
+create record:
+  lifetime: 7d
+  ttl-from-eol: true
+  extra-field: [a=1, b=2]
+`
+	tests := []struct {
+		name string
+		args []string
+		want map[string]string
+	}{
+		{"section wins over top level", nil, map[string]string{"lifetime": "7d", "ttl-from-eol": "true", "output-base": "base36", "extra-field": "a=1,b=2"}},
+		{"command line wins", []string{"--lifetime", "2h", "--output-base", "base32"}, map[string]string{"lifetime": "2h", "ttl-from-eol": "true", "output-base": "base32", "extra-field": "a=1,b=2"}},
+		{"conflicting flags give way", []string{"--eol", "2030-01-01T00:00:00", "--ttl", "5m"}, map[string]string{"eol": "2030-01-01T00:00:00", "ttl": "5m", "output-base": "base36", "extra-field": "a=1,b=2"}},
+		{"base shortcuts count as output-base", []string{"--base32"}, map[string]string{"lifetime": "7d", "ttl-from-eol": "true", "output-base": "base32", "base32": "true", "extra-field": "a=1,b=2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runWithConfig(t, config, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("%s is %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"unknown top level flag", "lifetim: 2h\n"},
+		{"unknown flag in a section", "create record:\n  lifetim: 2h\n"},
+		{"unknown command", "create recrd:\n  lifetime: 2h\n"},
+		{"conflicting values at one level", "create record:\n  lifetime: 2h\n  eol: 2030-01-01T00:00:00\n"},
+		{"list for a single value flag", "lifetime: [1h, 2h]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := runWithConfig(t, tt.config); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+	"github.com/libp2p/go-libp2p-core/crypto"
+)
+
+// corruptRecord introduces a single defect into a signed record so validators can be tested against it, defect may be:
+//   - signature: a byte of each signature is flipped
+//   - seqno, eol, value: the protobuf field no longer matches the signed CBOR data
+func corruptRecord(recBytes []byte, defect string) ([]byte, error) {
+	rec := &ipns_pb.IpnsEntry{}
+	if err := rec.Unmarshal(recBytes); err != nil {
+		return nil, err
+	}
+
+	switch defect {
+	case "signature":
+		if len(rec.SignatureV1) > 0 {
+			rec.SignatureV1[len(rec.SignatureV1)/2] ^= 0xff
+		}
+		if len(rec.SignatureV2) > 0 {
+			rec.SignatureV2[len(rec.SignatureV2)/2] ^= 0xff
+		}
+	case "seqno":
+		seqno := rec.GetSequence() + 1
+		rec.Sequence = &seqno
+	case "eol":
+		eol, err := time.Parse(time.RFC3339Nano, string(rec.GetValidity()))
+		if err != nil {
+			return nil, err
+		}
+		rec.Validity = []byte(eol.Add(time.Hour).UTC().Format(time.RFC3339Nano))
+	case "value":
+		rec.Value = append(rec.Value, []byte("-corrupted")...)
+	default:
+		return nil, fmt.Errorf("unknown defect %q, may be: signature, seqno, eol, or value", defect)
+	}
+
+	log.Infow("corrupted record", "defect", defect)
+	return rec.Marshal()
+}
+
+// withValidityType re-signs the record with the validity type replaced, so resolvers and parsers can be tested
+// against types other than EOL. The validity itself is kept, and the CBOR data is rebuilt from the standard fields.
+func withValidityType(recBytes []byte, priv crypto.PrivKey, typ ipns_pb.IpnsEntry_ValidityType) ([]byte, error) {
+	rec := &ipns_pb.IpnsEntry{}
+	if err := rec.Unmarshal(recBytes); err != nil {
+		return nil, err
+	}
+
+	rec.ValidityType = &typ
+	log.Infow("replaced validity type", "type", int32(typ))
+	return signPartialRecord(rec, priv)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	record_pb "github.com/libp2p/go-libp2p-record/pb"
+)
+
+// maxDatastoreFieldSize is the default bound on the length prefixes read from a datastore export
+const maxDatastoreFieldSize = 1 << 20
+
+// streamLimits cap how much of a framed stream or archive is read, so a corrupt or hostile length prefix or entry
+// count fails early with a clear error rather than allocating a huge buffer. A maxRecords of 0 means no limit.
+type streamLimits struct {
+	maxRecords    int
+	maxRecordSize int64
+}
+
+// checkCount returns an error once more than maxRecords entries have been read
+func (l streamLimits) checkCount(read int) error {
+	if l.maxRecords > 0 && read > l.maxRecords {
+		return fmt.Errorf("the input has more than the maximum of %d entries, raise --max-records if it is expected", l.maxRecords)
+	}
+	return nil
+}
+
+// dsKeyEncoding is how Kubo encodes binary keys in its datastore
+var dsKeyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// readDatastoreField reads a single uvarint length prefixed field, rejecting length prefixes over maxSize before reading
+func readDatastoreField(r *bufio.Reader, maxSize int64) ([]byte, error) {
+	l, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if l > uint64(maxSize) {
+		return nil, fmt.Errorf("field length %d is larger than the maximum of %d, the length prefix is likely corrupt (or raise --max-record-size)", l, maxSize)
+	}
+
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// ipnsRecordFromDatastore returns the IPNS name and record stored under a datastore key.
+// Kubo stores the records it publishes under /ipns/<base32 peer ID> and records it stores for the DHT
+// under /<base32 of /ipns/<peer ID>> wrapped in a libp2p record. ok is false for entries that are not IPNS records.
+func ipnsRecordFromDatastore(key string, value []byte) (name peer.ID, rec []byte, ok bool, err error) {
+	if strings.HasPrefix(key, "/ipns/") {
+		pid, err := dsKeyEncoding.DecodeString(strings.TrimPrefix(key, "/ipns/"))
+		if err != nil {
+			return "", nil, false, nil
+		}
+		name, err := peer.IDFromBytes(pid)
+		if err != nil {
+			return "", nil, false, nil
+		}
+		return name, value, true, nil
+	}
+
+	recordKey, err := dsKeyEncoding.DecodeString(strings.TrimPrefix(key, "/"))
+	if err != nil || !strings.HasPrefix(string(recordKey), "/ipns/") {
+		return "", nil, false, nil
+	}
+	name, err = peer.IDFromBytes(recordKey[len("/ipns/"):])
+	if err != nil {
+		return "", nil, false, nil
+	}
+
+	dhtRec := &record_pb.Record{}
+	if err := dhtRec.Unmarshal(value); err != nil {
+		return "", nil, false, fmt.Errorf("could not unmarshal DHT record: %w", err)
+	}
+	return name, dhtRec.GetValue(), true, nil
+}
+
+// parseDatastoreExport parses every IPNS record in a datastore export.
+// The export is a sequence of entries, each a uvarint length prefixed key followed by a uvarint length prefixed value.
+func parseDatastoreExport(path string, limits streamLimits, compact bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	results := []namedRecord{}
+	r := bufio.NewReader(f)
+	for read := 1; ; read++ {
+		key, err := readDatastoreField(r, limits.maxRecordSize)
+		if errors.Is(err, io.EOF) {
+			return printJSON(results, compact)
+		}
+		if err != nil {
+			return fmt.Errorf("could not read datastore key: %w", err)
+		}
+		if err := limits.checkCount(read); err != nil {
+			return err
+		}
+
+		value, err := readDatastoreField(r, limits.maxRecordSize)
+		if err != nil {
+			return fmt.Errorf("could not read datastore value for key %s: %w", key, err)
+		}
+
+		name, rec, ok, err := ipnsRecordFromDatastore(string(key), value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skipping %s: %v\n", key, err)
+			continue
+		}
+		if !ok {
+			log.Debugw("skipping non-IPNS datastore entry", "key", string(key))
+			continue
+		}
+
+		parsed, err := decodeIPNSRecord(rec, defaultCIDBase)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skipping %s: %v\n", key, err)
+			continue
+		}
+		results = append(results, namedRecord{Name: peer.ToCid(name).String(), Record: parsed})
+	}
+}
+
+// namedRecord is a parsed record along with the IPNS name it belongs to
+type namedRecord struct {
+	Name   string
+	Record *parsedRecord
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ipfs/go-ipns"
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// dedupeCandidate is a valid record read while deduplicating
+type dedupeCandidate struct {
+	path    string
+	data    []byte
+	rec     *ipns_pb.IpnsEntry
+	expired bool
+}
+
+// better returns whether c should be kept over o: unexpired records win, then the one go-ipns prefers (higher sequence number, then later EOL)
+func (c *dedupeCandidate) better(o *dedupeCandidate) (bool, error) {
+	if c.expired != o.expired {
+		return !c.expired, nil
+	}
+	cmp, err := ipns.Compare(c.rec, o.rec)
+	if err != nil {
+		return false, err
+	}
+	return cmp > 0, nil
+}
+
+// explainSelection describes, step by step, how c (A) and o (B) are compared by better: expiry, then the steps of
+// ipns.Compare (a V2 signature, the sequence number, the EOL). It stops at the first step that decides, a full tie keeps B.
+func (c *dedupeCandidate) explainSelection(o *dedupeCandidate) []string {
+	var steps []string
+	if c.expired != o.expired {
+		if c.expired {
+			return append(steps, "A has expired, B has not -> B wins")
+		}
+		return append(steps, "A has not expired, B has -> A wins")
+	}
+	if c.expired {
+		steps = append(steps, "A and B have both expired -> tie")
+	} else {
+		steps = append(steps, "A and B are both unexpired -> tie")
+	}
+
+	aV2, bV2 := c.rec.GetSignatureV2() != nil, o.rec.GetSignatureV2() != nil
+	switch {
+	case aV2 && !bV2:
+		return append(steps, "A has a V2 signature, B only V1 -> A wins")
+	case !aV2 && bV2:
+		return append(steps, "A only has a V1 signature, B has V2 -> B wins")
+	}
+	if aV2 {
+		steps = append(steps, "A and B both have V2 signatures -> tie")
+	} else {
+		steps = append(steps, "A and B only have V1 signatures -> tie")
+	}
+
+	as, bs := c.rec.GetSequence(), o.rec.GetSequence()
+	switch {
+	case as > bs:
+		return append(steps, fmt.Sprintf("A has seqno %d, B has seqno %d -> A wins", as, bs))
+	case as < bs:
+		return append(steps, fmt.Sprintf("A has seqno %d, B has seqno %d -> B wins", as, bs))
+	}
+	steps = append(steps, fmt.Sprintf("A has seqno %d, B has seqno %d -> tie", as, bs))
+
+	aEOL, aErr := ipns.GetEOL(c.rec)
+	bEOL, bErr := ipns.GetEOL(o.rec)
+	switch {
+	case aErr != nil || bErr != nil:
+		return append(steps, "the EOL of A or B can't be parsed -> no winner, B is kept")
+	case aEOL.After(bEOL):
+		return append(steps, fmt.Sprintf("A EOL %s is later than B EOL %s -> A wins", aEOL.Format(time.RFC3339), bEOL.Format(time.RFC3339)))
+	case bEOL.After(aEOL):
+		return append(steps, fmt.Sprintf("B EOL %s is later than A EOL %s -> B wins", bEOL.Format(time.RFC3339), aEOL.Format(time.RFC3339)))
+	}
+	return append(steps, fmt.Sprintf("A and B have the same EOL %s -> tie, B is kept", aEOL.Format(time.RFC3339)))
+}
+
+// dedupedName is the output of deduplicating the records of one name
+type dedupedName struct {
+	Name       string
+	Kept       string
+	Record     string
+	Duplicates int
+	Invalid    []string `json:",omitempty"`
+}
+
+// dedupeRecords keeps the best record for each IPNS name in dir and writes it to outDir as <name>.ipns-record.
+// Records that fail verification are never kept, expired records only when the name has nothing better.
+// Files that can't be read as records are reported at the end without stopping the others.
+// With explain, every comparison between two records of a name is explained on stderr, see explainSelection.
+func dedupeRecords(dir, outDir, decompress string, explain, compact bool) error {
+	best := make(map[peer.ID]*dedupeCandidate)
+	counts := make(map[peer.ID]int)
+	invalid := make(map[peer.ID][]string)
+
+	readErr := processDirectory(dir, false, func(path string) error {
+		data, err := readInput(path, "path", decompress, 0)
+		if err != nil {
+			return err
+		}
+		rec, err := unmarshalIPNSRecord(data)
+		if err != nil {
+			return err
+		}
+		name, err := nameForRecordFile(path, rec)
+		if err != nil {
+			return err
+		}
+
+		expired, err := verifyIPNSRecord(name, rec, true)
+		if err != nil {
+			log.Infow("dropping invalid record", "path", path, "error", err)
+			invalid[name] = append(invalid[name], path)
+			return nil
+		}
+		counts[name]++
+
+		c := &dedupeCandidate{path: path, data: data, rec: rec, expired: expired}
+		if prev, ok := best[name]; ok {
+			if explain {
+				fmt.Fprintf(os.Stderr, "%s: A is %s, B is %s (kept so far)\n", peer.ToCid(name), path, prev.path)
+				for _, step := range c.explainSelection(prev) {
+					fmt.Fprintf(os.Stderr, "  %s\n", step)
+				}
+			}
+			if better, err := c.better(prev); err != nil || !better {
+				return err
+			}
+		}
+		best[name] = c
+		return nil
+	})
+
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+
+	results := make([]dedupedName, 0, len(best))
+	for name, c := range best {
+		outPath := filepath.Join(outDir, peer.ToCid(name).String()+".ipns-record")
+		if err := os.WriteFile(outPath, c.data, 0o644); err != nil {
+			return err
+		}
+		results = append(results, dedupedName{
+			Name:       peer.ToCid(name).String(),
+			Kept:       c.path,
+			Record:     outPath,
+			Duplicates: counts[name] - 1,
+			Invalid:    invalid[name],
+		})
+	}
+	for name, paths := range invalid {
+		if _, ok := best[name]; !ok {
+			results = append(results, dedupedName{Name: peer.ToCid(name).String(), Invalid: paths})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	if err := printJSON(results, compact); err != nil {
+		return err
+	}
+	var dups int
+	for _, r := range results {
+		dups += r.Duplicates
+	}
+	fmt.Fprintf(os.Stderr, "kept %d records, collapsed %d duplicates\n", len(best), dups)
+	return readErr
+}
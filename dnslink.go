@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+const dnslinkPrefix = "dnslink="
+
+// resolveDNSLinkName looks up the _dnslink TXT record of the domain and returns the IPNS name it points at
+func resolveDNSLinkName(ctx context.Context, domain string) (peer.ID, error) {
+	domain = strings.TrimSuffix(domain, ".")
+	if domain == "" {
+		return "", errors.New("no domain specified")
+	}
+
+	lookup := "_dnslink." + domain
+	log.Infow("resolving dnslink", "domain", lookup)
+	txts, err := net.DefaultResolver.LookupTXT(ctx, lookup)
+	if err != nil {
+		return "", fmt.Errorf("could not resolve the dnslink of %s: %w", domain, err)
+	}
+
+	var links []string
+	for _, txt := range txts {
+		if strings.HasPrefix(txt, dnslinkPrefix) {
+			links = append(links, strings.TrimPrefix(txt, dnslinkPrefix))
+		}
+	}
+	sort.Strings(links)
+	switch len(links) {
+	case 0:
+		return "", fmt.Errorf("%s has no dnslink TXT record", lookup)
+	case 1:
+	default:
+		return "", fmt.Errorf("%s has %d dnslink TXT records, expected one: %s", lookup, len(links), strings.Join(links, ", "))
+	}
+
+	link := links[0]
+	log.Infow("resolved dnslink", "domain", domain, "link", link)
+	switch {
+	case strings.HasPrefix(link, "/ipfs/"):
+		return "", fmt.Errorf("the dnslink of %s points directly at content (%s) rather than an IPNS name, there is no record to verify", domain, link)
+	case strings.HasPrefix(link, "/ipns/"):
+		root := strings.SplitN(strings.TrimPrefix(link, "/ipns/"), "/", 2)[0]
+		name, err := decodeIPNSName(root)
+		if err != nil {
+			return "", fmt.Errorf("the dnslink of %s points at %s which is not an IPNS name, it may be another DNSLink domain: %w", domain, link, err)
+		}
+		return name, nil
+	default:
+		return "", fmt.Errorf("the dnslink of %s is not an /ipfs/ or /ipns/ path: %s", domain, link)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/go-ipns"
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// wrongSigningInput is a V2 signing input an implementation might mistakenly use instead of "ipns-signature:" + the CBOR data
+type wrongSigningInput struct {
+	description string
+	input       func(rec *ipns_pb.IpnsEntry) []byte
+}
+
+// prefixedData returns a signing input of the prefix followed by the record's CBOR data
+func prefixedData(prefix string) func(rec *ipns_pb.IpnsEntry) []byte {
+	return func(rec *ipns_pb.IpnsEntry) []byte {
+		return append([]byte(prefix), rec.GetData()...)
+	}
+}
+
+// wrongSigningInputs are the domain separation mistakes checked by checkDomainSeparation
+var wrongSigningInputs = []wrongSigningInput{
+	{`the CBOR data without a prefix`, prefixedData("")},
+	{`the prefix "ipns-signature" without the colon`, prefixedData("ipns-signature")},
+	{`the prefix "ipns-signature: " with a trailing space`, prefixedData("ipns-signature: ")},
+	{`the upper case prefix "IPNS-SIGNATURE:"`, prefixedData("IPNS-SIGNATURE:")},
+	{`the prefix "/ipns-signature:" with a leading slash`, prefixedData("/ipns-signature:")},
+	{`the V1 signing input (value + validity + validity type) instead of the CBOR data`, func(rec *ipns_pb.IpnsEntry) []byte {
+		v1, _ := recordSigningBytes(rec)
+		return v1
+	}},
+	{`the V1 signing input with the "ipns-signature:" prefix`, func(rec *ipns_pb.IpnsEntry) []byte {
+		v1, _ := recordSigningBytes(rec)
+		return append([]byte(ipnsSignatureV2Prefix), v1...)
+	}},
+}
+
+// checkDomainSeparation checks the record's V2 signature is over "ipns-signature:" + the CBOR data. When it isn't, the
+// signature is tried against wrongSigningInputs so the error can say which mistake the publishing implementation made.
+func checkDomainSeparation(name peer.ID, rec *ipns_pb.IpnsEntry) error {
+	if rec.SignatureV2 == nil {
+		return errors.New("the record has no V2 signature, there is no domain separation to check")
+	}
+	if len(rec.GetData()) == 0 {
+		return errors.New("the record has a V2 signature but no CBOR data for it to sign")
+	}
+	if err := checkEmbeddedPublicKey(name, rec); err != nil {
+		return err
+	}
+	pk, err := ipns.ExtractPublicKey(name, rec)
+	if err != nil {
+		return err
+	}
+
+	_, v2 := recordSigningBytes(rec)
+	if ok, err := pk.Verify(v2, rec.SignatureV2); err == nil && ok {
+		return nil
+	}
+	for _, w := range wrongSigningInputs {
+		if ok, err := pk.Verify(w.input(rec), rec.SignatureV2); err == nil && ok {
+			return fmt.Errorf("the V2 signature only validates over %s, it must be over %q + the CBOR data. Implementations following the spec reject this record", w.description, ipnsSignatureV2Prefix)
+		}
+	}
+	return fmt.Errorf("the V2 signature does not validate over %q + the CBOR data or any known wrong prefix, it was not made by the key for %s or the record was modified", ipnsSignatureV2Prefix, peer.ToCid(name))
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-ipns"
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// driftRecord is the part of a record compared when checking for drift
+type driftRecord struct {
+	SequenceNumber uint64
+	Value          string
+	EOL            string
+	Expired        bool
+}
+
+// driftEntry is the drift check of one expected record against the network
+type driftEntry struct {
+	File        string
+	Name        string `json:",omitempty"`
+	Status      string
+	Expected    *driftRecord `json:",omitempty"`
+	Network     *driftRecord `json:",omitempty"`
+	Differences []string     `json:",omitempty"`
+	Error       string       `json:",omitempty"`
+}
+
+// driftSummary counts the drift check results by status
+type driftSummary struct {
+	Total   int
+	InSync  int
+	Drifted int
+	Errors  int
+}
+
+// driftReport is the output of drift
+type driftReport struct {
+	Summary driftSummary
+	Records []driftEntry
+}
+
+// newDriftRecord returns the compared fields of the record, expired is whether its EOL has passed
+func newDriftRecord(rec *ipns_pb.IpnsEntry, now time.Time) (*driftRecord, error) {
+	eol, err := ipns.GetEOL(rec)
+	if err != nil {
+		return nil, err
+	}
+	return &driftRecord{
+		SequenceNumber: rec.GetSequence(),
+		Value:          string(rec.GetValue()),
+		EOL:            eol.Format(time.RFC3339Nano),
+		Expired:        !eol.After(now),
+	}, nil
+}
+
+// detectDrift compares every record in expectedDir with the record currently served for its name by the gateway and
+// outputs a report. Names are taken from the file names or embedded public keys as in verify record.
+// Up to concurrency names are checked at a time, with requests to the gateway spaced out by the limiter.
+// Every record is checked, an error is returned at the end if any of them drifted or could not be checked.
+func detectDrift(ctx context.Context, expectedDir, gateway, decompress string, timeout time.Duration, concurrency int, limiter *requestLimiter, compact bool) error {
+	if concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1, got %d", concurrency)
+	}
+
+	var paths []string
+	err := processDirectory(expectedDir, false, func(path string) error {
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	report := driftReport{Records: make([]driftEntry, len(paths))}
+	pending := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pending {
+				report.Records[i].File = paths[i]
+				checkDrift(ctx, paths[i], gateway, decompress, timeout, limiter, now, &report.Records[i])
+			}
+		}()
+	}
+	for i := range paths {
+		pending <- i
+	}
+	close(pending)
+	wg.Wait()
+
+	for _, e := range report.Records {
+		report.Summary.Total++
+		switch e.Status {
+		case "in-sync":
+			report.Summary.InSync++
+		case "drifted":
+			report.Summary.Drifted++
+		default:
+			report.Summary.Errors++
+		}
+	}
+	if err := printJSON(report, compact); err != nil {
+		return err
+	}
+
+	if s := report.Summary; s.Drifted > 0 || s.Errors > 0 {
+		return fmt.Errorf("%d of %d names drifted, %d could not be checked", s.Drifted, s.Total, s.Errors)
+	}
+	return nil
+}
+
+// checkDrift fills in entry with the comparison of the expected record at path and the network record for its name
+func checkDrift(ctx context.Context, path, gateway, decompress string, timeout time.Duration, limiter *requestLimiter, now time.Time, entry *driftEntry) {
+	entry.Status = "error"
+
+	expected, err := readIPNSRecordFile(path, decompress)
+	if err != nil {
+		entry.Error = err.Error()
+		return
+	}
+	name, err := nameForRecordFile(path, expected)
+	if err != nil {
+		entry.Error = err.Error()
+		return
+	}
+	entry.Name = peer.ToCid(name).String()
+	if entry.Expected, err = newDriftRecord(expected, now); err != nil {
+		entry.Error = fmt.Sprintf("expected record: %v", err)
+		return
+	}
+
+	data, err := fetchWithBackoff(ctx, limiter, gateway, name, timeout)
+	if err != nil {
+		entry.Error = err.Error()
+		return
+	}
+	network, err := unmarshalIPNSRecord(data)
+	if err != nil {
+		entry.Error = fmt.Sprintf("network record: %v", err)
+		return
+	}
+	if _, err := verifyIPNSRecord(name, network, true); err != nil {
+		entry.Error = fmt.Sprintf("the network record is invalid: %v", err)
+		return
+	}
+	if entry.Network, err = newDriftRecord(network, now); err != nil {
+		entry.Error = fmt.Sprintf("network record: %v", err)
+		return
+	}
+
+	entry.Differences = driftDifferences(entry.Expected, entry.Network)
+	entry.Status = "in-sync"
+	if len(entry.Differences) > 0 {
+		entry.Status = "drifted"
+	}
+}
+
+// driftDifferences describes how the network record differs from the expected one
+func driftDifferences(expected, network *driftRecord) []string {
+	var diffs []string
+	switch {
+	case network.SequenceNumber > expected.SequenceNumber:
+		diffs = append(diffs, fmt.Sprintf("newer sequence number on the network: %d, expected %d", network.SequenceNumber, expected.SequenceNumber))
+	case network.SequenceNumber < expected.SequenceNumber:
+		diffs = append(diffs, fmt.Sprintf("older sequence number on the network: %d, expected %d", network.SequenceNumber, expected.SequenceNumber))
+	}
+	if network.Value != expected.Value {
+		diffs = append(diffs, fmt.Sprintf("value changed: %s, expected %s", network.Value, expected.Value))
+	}
+	if network.Expired {
+		diffs = append(diffs, "the network record expired at "+network.EOL)
+	}
+	return diffs
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ipfs/go-ipns"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multibase"
+)
+
+// keyDump is the output of dump.
+// DHTRecordKey is the routing key records are stored under, /ipns/ followed by the raw peer ID bytes, as base16
+// multibase. DHTRecordKeyDisplay is the same key with the peer ID written out, as it's usually shown in logs.
+type keyDump struct {
+	KeyType             string
+	PeerID              string
+	Names               map[string]string
+	PubSubTopic         string
+	DHTRendezvousKey    string
+	DHTRecordKey        string
+	DHTRecordKeyDisplay string
+}
+
+const (
+	nameCodecLibp2pKey = "libp2p-key"
+	nameCodecPeerID    = "peer-id"
+)
+
+// ipnsNames returns the IPNS name for the peer ID in the bases commonly used with the codec.
+// The libp2p-key codec gives a CIDv1 while peer-id gives the bare multihash that some older APIs expect.
+func ipnsNames(pid peer.ID, codec string) (map[string]string, error) {
+	names := make(map[string]string)
+	switch codec {
+	case nameCodecLibp2pKey:
+		name := peer.ToCid(pid)
+		for _, base := range []multibase.Encoding{multibase.Base32, multibase.Base36} {
+			s, err := name.StringOfBase(base)
+			if err != nil {
+				return nil, err
+			}
+			names[multibase.EncodingToStr[base]] = s
+		}
+	case nameCodecPeerID:
+		// peer IDs are conventionally base58btc without a multibase prefix
+		names[multibase.EncodingToStr[multibase.Base58BTC]] = peer.Encode(pid)
+	default:
+		return nil, fmt.Errorf("unknown name codec %q, may be: %s or %s", codec, nameCodecLibp2pKey, nameCodecPeerID)
+	}
+	return names, nil
+}
+
+// dumpKey prints everything that can be derived from the key in one JSON object
+func dumpKey(priv crypto.PrivKey, nameCodec string, compact bool) error {
+	pid, err := peer.IDFromPublicKey(priv.GetPublic())
+	if err != nil {
+		return err
+	}
+
+	names, err := ipnsNames(pid, nameCodec)
+	if err != nil {
+		return err
+	}
+
+	topic, err := getPubSubTopic(peer.ToCid(pid).String())
+	if err != nil {
+		return err
+	}
+
+	rendezvous, err := getDHTRendezvousKey(topic)
+	if err != nil {
+		return err
+	}
+
+	recordKey, err := multibase.Encode(multibase.Base16, []byte(ipns.RecordKey(pid)))
+	if err != nil {
+		return err
+	}
+
+	return printJSON(keyDump{
+		KeyType:             priv.Type().String(),
+		PeerID:              peer.Encode(pid),
+		Names:               names,
+		PubSubTopic:         topic,
+		DHTRendezvousKey:    rendezvous,
+		DHTRecordKey:        recordKey,
+		DHTRecordKeyDisplay: "/ipns/" + peer.Encode(pid),
+	}, compact)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// reconstructEOLLayout is create record's --eol layout with the fractional seconds records keep, which it also parses
+const reconstructEOLLayout = "2006-01-02T15:04:05.999999999"
+
+// shellQuote quotes s so a POSIX shell reads it back unchanged
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// printRecordEnv prints the record's fields as shell variable assignments for eval or source.
+// Fields the record doesn't have are set to the empty string, the epoch EOL variables are only printed with --since-epoch
+// and the field variables with --proto-version.
+func printRecordEnv(rec *parsedRecord) {
+	ttl := ""
+	if rec.TTL != nil {
+		ttl = *rec.TTL
+	}
+
+	vars := []struct{ name, value string }{
+		{"IPNS_VALUE", rec.Value},
+		{"IPNS_CID", rec.Path.CID},
+		{"IPNS_SEQNO", fmt.Sprint(rec.SequenceNumber)},
+		{"IPNS_EOL", rec.EOL},
+		{"IPNS_TTL", ttl},
+		{"IPNS_PUBKEY", rec.PubKey},
+	}
+	if rec.EOLUnix != nil {
+		vars = append(vars,
+			struct{ name, value string }{"IPNS_EOL_RFC3339", rec.EOLRFC3339},
+			struct{ name, value string }{"IPNS_EOL_UNIX", fmt.Sprint(*rec.EOLUnix)},
+			struct{ name, value string }{"IPNS_EOL_UNIX_NANO", fmt.Sprint(*rec.EOLUnixNano)},
+		)
+	}
+	if rec.Shape != nil {
+		vars = append(vars,
+			struct{ name, value string }{"IPNS_FIELDS", strings.Join(rec.Shape.Fields, ",")},
+			struct{ name, value string }{"IPNS_SIGNATURES", rec.Shape.Signatures},
+		)
+	}
+	for _, v := range vars {
+		fmt.Printf("%s=%s\n", v.name, shellQuote(v.value))
+	}
+}
+
+// printReconstructArgs prints the create record flags that reproduce the record's value, sequence number, EOL, and TTL,
+// shell quoted for eval. The TTL flag is left out when the record has none, and extra CBOR fields are only warned about.
+func printReconstructArgs(rec *parsedRecord) {
+	args := []string{
+		"--value", shellQuote(rec.Value),
+		"--seqno", fmt.Sprint(rec.SequenceNumber),
+		"--eol", rec.eol.UTC().Format(reconstructEOLLayout),
+	}
+	if rec.TTL != nil {
+		args = append(args, "--ttl", *rec.TTL)
+	}
+	if len(rec.ExtraFields) > 0 {
+		fmt.Fprintf(os.Stderr, "warning: the record has %d extra CBOR fields, they are not part of the arguments\n", len(rec.ExtraFields))
+	}
+	if !rec.eol.After(time.Now()) {
+		fmt.Fprintln(os.Stderr, "warning: the record has expired, a record created with these arguments is expired too")
+	}
+	fmt.Println(strings.Join(args, " "))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+	"github.com/libp2p/go-libp2p-core/crypto"
+)
+
+// maximalRecordTTL is the TTL given to --maximal records when --ttl isn't set
+const maximalRecordTTL = time.Hour
+
+// fieldSetFlag returns the field set chosen with --minimal or --maximal, or "" when neither is set
+func fieldSetFlag(minimal, maximal bool) (string, error) {
+	switch {
+	case minimal && maximal:
+		return "", errors.New("cannot use --minimal and --maximal together, choose one")
+	case minimal:
+		return "minimal", nil
+	case maximal:
+		return "maximal", nil
+	}
+	return "", nil
+}
+
+// applyFieldSet strips a signed record down to, or fills it out with, the optional protobuf fields for testing how
+// lenient resolvers are. set may be:
+//   - minimal: only what a V2 record needs, no protobuf TTL (the CBOR data still has 0), no V1 signature, and the public key only if the name can't inline it
+//   - maximal: both signatures, and the public key embedded even when the name inlines it
+//
+// Neither signature covers the protobuf TTL, V1 signature, or public key, so the record stays valid either way.
+// V1-only resolvers reject minimal records since they have no V1 signature.
+func applyFieldSet(recBytes []byte, pub crypto.PubKey, set string) ([]byte, error) {
+	if set == "" {
+		return recBytes, nil
+	}
+
+	rec := &ipns_pb.IpnsEntry{}
+	if err := rec.Unmarshal(recBytes); err != nil {
+		return nil, err
+	}
+
+	switch set {
+	case "minimal":
+		rec.Ttl = nil
+		rec.SignatureV1 = nil
+	case "maximal":
+		if len(rec.SignatureV1) == 0 || len(rec.SignatureV2) == 0 {
+			return nil, errors.New("the record is missing a signature, it can't be maximal")
+		}
+		pk, err := crypto.MarshalPublicKey(pub)
+		if err != nil {
+			return nil, err
+		}
+		rec.PubKey = pk
+	default:
+		return nil, fmt.Errorf("unknown field set %q, may be: minimal or maximal", set)
+	}
+	return rec.Marshal()
+}
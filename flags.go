@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+var extendedDurationUnit = regexp.MustCompile(`(\d+(?:\.\d+)?)([dwy])`)
+
+var extendedDurationHours = map[string]float64{
+	"d": 24,
+	"w": 24 * 7,
+	"y": 24 * 365,
+}
+
+// parseDuration parses a Go duration that may also use d (days), w (weeks), and y (365 day years) units, e.g. 1d12h
+func parseDuration(s string) (time.Duration, error) {
+	var convErr error
+	expanded := extendedDurationUnit.ReplaceAllStringFunc(s, func(m string) string {
+		parts := extendedDurationUnit.FindStringSubmatch(m)
+		n, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			convErr = err
+			return m
+		}
+		return strconv.FormatFloat(n*extendedDurationHours[parts[2]], 'f', -1, 64) + "h"
+	})
+	if convErr != nil {
+		return 0, convErr
+	}
+
+	d, err := time.ParseDuration(expanded)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+var sizeUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"k":   1000,
+	"kb":  1000,
+	"kib": 1 << 10,
+	"m":   1000 * 1000,
+	"mb":  1000 * 1000,
+	"mib": 1 << 20,
+	"g":   1000 * 1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"gib": 1 << 30,
+}
+
+var sizePattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([a-zA-Z]*)$`)
+
+// parseSize parses a number of bytes with an optional SI (KB, MB, GB) or IEC (KiB, MiB, GiB) suffix, e.g. 10KiB
+func parseSize(s string) (int64, error) {
+	m := sizePattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	unit, ok := sizeUnits[strings.ToLower(m[2])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q: unknown unit %q", s, m[2])
+	}
+
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// durationValue is a flag value accepting the durations understood by parseDuration
+type durationValue struct {
+	d time.Duration
+}
+
+func (v *durationValue) Set(s string) error {
+	d, err := parseDuration(s)
+	if err != nil {
+		return err
+	}
+	v.d = d
+	return nil
+}
+
+func (v *durationValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.d.String()
+}
+
+// sizeValue is a flag value accepting the sizes understood by parseSize
+type sizeValue struct {
+	n int64
+}
+
+func (v *sizeValue) Set(s string) error {
+	n, err := parseSize(s)
+	if err != nil {
+		return err
+	}
+	v.n = n
+	return nil
+}
+
+func (v *sizeValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(v.n, 10)
+}
+
+// checkInputSize returns an error if the data is larger than the maximum size, a maximum of 0 means no limit
+func checkInputSize(data []byte, max *sizeValue) error {
+	if max.n > 0 && int64(len(data)) > max.n {
+		return fmt.Errorf("input is %d bytes which is larger than the maximum size of %d bytes", len(data), max.n)
+	}
+	return nil
+}
+
+// defaultTimeFormat is the --time-format timestamps are output in when it isn't set
+const defaultTimeFormat = "rfc3339"
+
+// formatTime formats t as the --time-format says, which may be rfc3339 (in UTC), unix (seconds since the epoch),
+// go (time.Time's String), or anything else as a Go time layout
+func formatTime(t time.Time, format string) string {
+	switch format {
+	case "rfc3339":
+		return t.UTC().Format(time.RFC3339Nano)
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "go":
+		return t.String()
+	default:
+		return t.Format(format)
+	}
+}
+
+// baseShortcuts are the multibases with a boolean shortcut flag for --output-base, e.g. --base36
+var baseShortcuts = []string{"base36", "base32", "base58btc", "base64url"}
+
+// addBaseShortcutFlags adds the base shortcut flags to every command with an --output-base flag.
+// The shortcut is applied after the config file and before the command's own Before runs.
+func addBaseShortcutFlags(commands []*cli.Command) {
+	for _, cmd := range commands {
+		addBaseShortcutFlags(cmd.Subcommands)
+		if !hasFlag(cmd, "output-base") {
+			continue
+		}
+
+		for _, base := range baseShortcuts {
+			cmd.Flags = append(cmd.Flags, &cli.BoolFlag{
+				Required: false,
+				Name:     base,
+				Usage:    "shorthand for --output-base " + base,
+			})
+		}
+		before := cmd.Before
+		cmd.Before = func(c *cli.Context) error {
+			if err := applyBaseShortcut(c); err != nil {
+				return err
+			}
+			if before != nil {
+				return before(c)
+			}
+			return nil
+		}
+	}
+}
+
+// hasFlag returns whether the command has a flag with the name
+func hasFlag(cmd *cli.Command, name string) bool {
+	for _, f := range cmd.Flags {
+		for _, n := range f.Names() {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyBaseShortcut sets --output-base from a base shortcut flag, at most one of them may be passed and not with --output-base
+func applyBaseShortcut(c *cli.Context) error {
+	var set []string
+	if c.IsSet("output-base") {
+		set = append(set, "--output-base")
+	}
+	var base string
+	for _, b := range baseShortcuts {
+		if c.Bool(b) {
+			set = append(set, "--"+b)
+			base = b
+		}
+	}
+	if len(set) > 1 {
+		return fmt.Errorf("cannot use %s together, choose one output base", strings.Join(set, " and "))
+	}
+	if base == "" {
+		return nil
+	}
+	return c.Set("output-base", base)
+}
+
+var ratePattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)(?:/([smh]))?$`)
+
+var rateUnits = map[string]float64{
+	"":  1,
+	"s": 1,
+	"m": 60,
+	"h": 60 * 60,
+}
+
+// rateValue is a flag value accepting a number of requests per second, minute, or hour, e.g. 10/s or 600/m.
+// A bare number is per second and 0 means no limit.
+type rateValue struct {
+	perSecond float64
+}
+
+func (v *rateValue) Set(s string) error {
+	m := ratePattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return fmt.Errorf("invalid rate %q, expected a number of requests per second, minute, or hour, e.g. 10/s or 600/m", s)
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return fmt.Errorf("invalid rate %q: %w", s, err)
+	}
+	v.perSecond = n / rateUnits[m[2]]
+	return nil
+}
+
+func (v *rateValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(v.perSecond, 'f', -1, 64) + "/s"
+}
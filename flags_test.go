@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"90s", 90 * time.Second},
+		{"1h30m", 90 * time.Minute},
+		{"1d", 24 * time.Hour},
+		{"1d12h", 36 * time.Hour},
+		{"1.5d", 36 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+		{"1y", 365 * 24 * time.Hour},
+		{"1y1d1h", 366*24*time.Hour + time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseDuration(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []string{"", "1", "d", "1x", "1d2"} {
+		if _, err := parseDuration(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"512", 512},
+		{"512B", 512},
+		{"10KB", 10000},
+		{"10k", 10000},
+		{"10KiB", 10 << 10},
+		{"1.5MiB", 3 << 19},
+		{"2 MB", 2000000},
+		{"1gib", 1 << 30},
+		{" 1G ", 1000000000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseSize(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []string{"", "KB", "-1", "10TB", "1e3", "ten"} {
+		if _, err := parseSize(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+const ipnsRecordContentType = "application/vnd.ipfs.ipns-record"
+
+// maxFetchedRecordSize is the IPNS spec's limit on record size, larger responses from gateways are rejected
+const maxFetchedRecordSize = 10 << 10
+
+// routingIPNSURL returns the HTTP routing API URL for the given IPNS name on the gateway
+func routingIPNSURL(gateway, ipnsKey string) (string, error) {
+	pid, err := decodeIPNSName(ipnsKey)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(gateway, "/") + "/routing/v1/ipns/" + peer.ToCid(pid).String(), nil
+}
+
+// writeHTTPResponse writes the record framed the way the routing API responds to GET /routing/v1/ipns/{name}.
+// The Cache-Control max-age is the record's TTL, when it has one.
+func writeHTTPResponse(w io.Writer, record []byte, ttl *time.Duration) error {
+	resp := &http.Response{
+		StatusCode:    http.StatusOK,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		ContentLength: int64(len(record)),
+		Body:          io.NopCloser(bytes.NewReader(record)),
+	}
+	resp.Header.Set("Content-Type", ipnsRecordContentType)
+	if ttl != nil {
+		resp.Header.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int64(ttl.Seconds())))
+	}
+	return resp.Write(w)
+}
+
+// unwrapHTTPResponse returns the record from a routing API response written by writeHTTPResponse or a server
+func unwrapHTTPResponse(data []byte) ([]byte, error) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not read HTTP response: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP response does not contain a record: %s", resp.Status)
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != ipnsRecordContentType {
+		return nil, fmt.Errorf("HTTP response content type is %q, expected %s", resp.Header.Get("Content-Type"), ipnsRecordContentType)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// routingJSONRecord is a record in a JSON routing API response, the record itself is base64 encoded
+type routingJSONRecord struct {
+	Record string
+}
+
+// routingJSONResponse covers the shapes of JSON routing API responses: a single record,
+// a Records list, or a list of records at the top level.
+type routingJSONResponse struct {
+	routingJSONRecord
+	Records []routingJSONRecord
+}
+
+// routingJSONRecords returns the records in a JSON routing API response body.
+// The body may hold a single {"Record": ...} object, a {"Records": [...]} object, a JSON array of records,
+// or several of these one after another as in newline delimited (ndjson) streaming responses.
+func routingJSONRecords(data []byte) ([][]byte, error) {
+	var entries []routingJSONRecord
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("could not decode routing JSON response: %w", err)
+		}
+
+		if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+			var list []routingJSONRecord
+			if err := json.Unmarshal(raw, &list); err != nil {
+				return nil, fmt.Errorf("could not decode routing JSON response: %w", err)
+			}
+			entries = append(entries, list...)
+			continue
+		}
+
+		var resp routingJSONResponse
+		if err := json.Unmarshal(raw, &resp); err != nil {
+			return nil, fmt.Errorf("could not decode routing JSON response: %w", err)
+		}
+		if resp.Record != "" {
+			entries = append(entries, resp.routingJSONRecord)
+		}
+		entries = append(entries, resp.Records...)
+	}
+
+	records := make([][]byte, 0, len(entries))
+	for i, e := range entries {
+		if e.Record == "" {
+			return nil, fmt.Errorf("entry %d of the routing JSON response has no Record", i)
+		}
+		rec, err := decodeBase64(e.Record)
+		if err != nil {
+			return nil, fmt.Errorf("could not base64 decode record %d of the routing JSON response: %w", i, err)
+		}
+		records = append(records, rec)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("routing JSON response does not contain any records")
+	}
+	return records, nil
+}
+
+// decodeBase64 decodes standard or URL safe base64, padded or not
+func decodeBase64(s string) ([]byte, error) {
+	enc := base64.StdEncoding
+	if strings.ContainsAny(s, "-_") {
+		enc = base64.URLEncoding
+	}
+	if !strings.HasSuffix(s, "=") {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+	return enc.DecodeString(s)
+}
+
+// publishToGateway PUTs the record to the gateway's routing API.
+// With dryRun the record is validated against the name and the request that would be made is printed instead.
+func publishToGateway(ctx context.Context, gateway, ipnsKey string, record []byte, dryRun bool) error {
+	url, err := routingIPNSURL(gateway, ipnsKey)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		name, err := decodeIPNSName(ipnsKey)
+		if err != nil {
+			return err
+		}
+		rec, err := unmarshalIPNSRecord(record)
+		if err != nil {
+			return err
+		}
+		if _, err := verifyIPNSRecord(name, rec, false); err != nil {
+			return err
+		}
+
+		fmt.Printf("dry run: would PUT %d byte record to %s\n", len(record), url)
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(record))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ipnsRecordContentType)
+
+	log.Infow("publishing record to gateway", "url", url, "size", len(record))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	log.Infow("gateway responded", "url", url, "status", resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s %s\n", resp.Proto, resp.Status)
+	if len(body) > 0 {
+		fmt.Println(string(body))
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("gateway rejected the record: %s", resp.Status)
+	}
+	return nil
+}
+
+// fetchFromGateway GETs the record for the IPNS name from the gateway's routing API
+func fetchFromGateway(ctx context.Context, gateway string, name peer.ID) ([]byte, error) {
+	url, err := routingIPNSURL(gateway, peer.ToCid(name).String())
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", ipnsRecordContentType)
+
+	log.Infow("fetching record from gateway", "url", url)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	log.Infow("gateway responded", "url", url, "status", resp.StatusCode)
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, &rateLimitedError{gateway: gateway, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not fetch the record for %s from %s: %s", peer.ToCid(name), gateway, resp.Status)
+	}
+	record, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchedRecordSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(record) > maxFetchedRecordSize {
+		return nil, fmt.Errorf("the record for %s from %s is larger than the %d byte limit", peer.ToCid(name), gateway, maxFetchedRecordSize)
+	}
+	return record, nil
+}
+
+// checkValueOnGateway confirms the gateway can serve the content path the record will point to
+func checkValueOnGateway(ctx context.Context, gateway, value string, timeout time.Duration) error {
+	if !strings.HasPrefix(value, "/ipfs/") && !strings.HasPrefix(value, "/ipns/") {
+		return fmt.Errorf("cannot check value %q on a gateway, it is not an /ipfs/ or /ipns/ path", value)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	url := strings.TrimSuffix(gateway, "/") + value
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+
+	log.Infow("checking value is retrievable", "url", url)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not check value on the gateway: %w", err)
+	}
+	resp.Body.Close()
+	log.Infow("gateway responded", "url", url, "status", resp.StatusCode)
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("value %s is not retrievable from %s: %s", value, gateway, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-ipns"
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+)
+
+// defaultGatewayMaxTTL is the longest TTL assumed to be honoured by CDN-style gateways before they clamp it
+const defaultGatewayMaxTTL = 24 * time.Hour
+
+// gatewayCaching describes how a gateway serving the record over the routing API is expected to cache it.
+// MaxAge is the Cache-Control max-age it would send, after clamping the TTL to the gateway's maximum and to the time left until the EOL.
+type gatewayCaching struct {
+	TTL       *time.Duration
+	MaxAge    time.Duration
+	Cacheable bool
+}
+
+// checkGatewayPolicy works out the effective caching of the record by a gateway that accepts TTLs up to maxTTL.
+// The warnings are for TTLs such gateways won't use as is: missing, 0 (uncacheable), or over maxTTL.
+func checkGatewayPolicy(rec *ipns_pb.IpnsEntry, maxTTL time.Duration, now time.Time) (*gatewayCaching, []string, error) {
+	eol, err := ipns.GetEOL(rec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var warnings []string
+	caching := &gatewayCaching{}
+	if rec.Ttl == nil {
+		warnings = append(warnings, "the record has no TTL, gateways will cache it for their own default")
+		return caching, warnings, nil
+	}
+
+	ttl := time.Duration(rec.GetTtl())
+	caching.TTL = &ttl
+	caching.MaxAge = ttl
+	if ttl == 0 {
+		warnings = append(warnings, "the TTL is 0, gateways and CDNs will not cache the record and every resolution reaches the network")
+		return caching, warnings, nil
+	}
+	if ttl > maxTTL {
+		warnings = append(warnings, fmt.Sprintf("the TTL %v is more than the gateway maximum of %v, it will be clamped to %v", ttl, maxTTL, maxTTL))
+		caching.MaxAge = maxTTL
+	}
+	if untilEOL := eol.Sub(now); caching.MaxAge > untilEOL {
+		if untilEOL < 0 {
+			untilEOL = 0
+		}
+		warnings = append(warnings, fmt.Sprintf("the record expires in %v, caching is cut short at its EOL", untilEOL.Round(time.Second)))
+		caching.MaxAge = untilEOL
+	}
+	caching.MaxAge = caching.MaxAge.Truncate(time.Second)
+	caching.Cacheable = caching.MaxAge > 0
+	return caching, warnings, nil
+}
+
+// String is the effective caching as reported by verify record --gateway-policy
+func (g *gatewayCaching) String() string {
+	switch {
+	case g.TTL == nil:
+		return "gateway caching: gateway default (no TTL)"
+	case !g.Cacheable:
+		return fmt.Sprintf("gateway caching: uncacheable (TTL %v)", *g.TTL)
+	default:
+		return fmt.Sprintf("gateway caching: Cache-Control: public, max-age=%d (TTL %v)", int64(g.MaxAge.Seconds()), *g.TTL)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ipnsFieldNames are the names of the IpnsEntry protobuf fields by field number
+var ipnsFieldNames = map[uint64]string{
+	1: "value",
+	2: "signatureV1",
+	3: "validityType",
+	4: "validity",
+	5: "sequence",
+	6: "ttl",
+	7: "pubKey",
+	8: "signatureV2",
+	9: "data",
+}
+
+// hexdumpWidth is the number of bytes on each hex dump line
+const hexdumpWidth = 16
+
+// protoField is a protobuf field located in a record, end is the offset after its value
+type protoField struct {
+	start, end int
+	number     uint64
+	wireType   uint64
+	length     int
+}
+
+// readProtoField reads the field starting at offset off of data
+func readProtoField(data []byte, off int) (protoField, error) {
+	f := protoField{start: off}
+	key, n := binary.Uvarint(data[off:])
+	if n <= 0 {
+		return f, errors.New("truncated field key")
+	}
+	f.number, f.wireType = key>>3, key&7
+	off += n
+
+	switch f.wireType {
+	case 0:
+		if _, n = binary.Uvarint(data[off:]); n <= 0 {
+			return f, errors.New("truncated varint")
+		}
+		f.length = n
+	case 1:
+		f.length = 8
+	case 2:
+		l, n := binary.Uvarint(data[off:])
+		if n <= 0 {
+			return f, errors.New("truncated length")
+		}
+		off += n
+		if l > uint64(len(data)-off) {
+			return f, fmt.Errorf("length %d runs past the end of the record", l)
+		}
+		f.length = int(l)
+	case 5:
+		f.length = 4
+	default:
+		return f, fmt.Errorf("unsupported wire type %d", f.wireType)
+	}
+	if off+f.length > len(data) {
+		return f, errors.New("value runs past the end of the record")
+	}
+	f.end = off + f.length
+	return f, nil
+}
+
+// describe returns the annotation for the field
+func (f protoField) describe() string {
+	name, ok := ipnsFieldNames[f.number]
+	if !ok {
+		name = "unknown"
+	}
+	types := map[uint64]string{0: "varint", 1: "fixed64", 2: "bytes", 5: "fixed32"}
+	return fmt.Sprintf("field %d (%s), %s, %d byte value", f.number, name, types[f.wireType], f.length)
+}
+
+// writeHexdump writes the record as a hex dump with a line marking where each protobuf field starts.
+// When the record is malformed the bytes from the bad field on are dumped unannotated and the error is returned after the dump.
+func writeHexdump(w io.Writer, data []byte) error {
+	var parseErr error
+	for off := 0; off < len(data); {
+		f, err := readProtoField(data, off)
+		if err != nil {
+			parseErr = fmt.Errorf("malformed record at offset 0x%x: %w", off, err)
+			fmt.Fprintf(w, "# 0x%04x: %v\n", off, parseErr)
+			writeHexLines(w, data, off, len(data))
+			break
+		}
+		fmt.Fprintf(w, "# 0x%04x: %s\n", off, f.describe())
+		writeHexLines(w, data, f.start, f.end)
+		off = f.end
+	}
+	fmt.Fprintf(w, "# 0x%04x: end, %d bytes\n", len(data), len(data))
+	return parseErr
+}
+
+// writeHexLines writes data[start:end] as hex dump lines of the offset, the bytes in hex, and the printable ASCII
+func writeHexLines(w io.Writer, data []byte, start, end int) {
+	for off := start; off < end; off += hexdumpWidth {
+		lineEnd := off + hexdumpWidth
+		if lineEnd > end {
+			lineEnd = end
+		}
+		line := data[off:lineEnd]
+
+		var hex, ascii strings.Builder
+		for i := 0; i < hexdumpWidth; i++ {
+			if i == hexdumpWidth/2 {
+				hex.WriteByte(' ')
+			}
+			if i >= len(line) {
+				hex.WriteString("   ")
+				continue
+			}
+			fmt.Fprintf(&hex, "%02x ", line[i])
+			if line[i] >= 0x20 && line[i] < 0x7f {
+				ascii.WriteByte(line[i])
+			} else {
+				ascii.WriteByte('.')
+			}
+		}
+		fmt.Fprintf(w, "%08x  %s |%s|\n", off, hex.String(), ascii.String())
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multibase"
+)
+
+// ipnsSignatureV2Prefix is prepended to the CBOR data to get the V2 signing input
+const ipnsSignatureV2Prefix = "ipns-signature:"
+
+// signingBytes are the exact inputs to the record signatures, V2 is nil when the record has no CBOR data
+type signingBytes struct {
+	V1 string
+	V2 *string
+}
+
+// recordSigningBytes returns the V1 and V2 signing inputs the same way go-ipns computes them.
+// V1 is value + validity + the validity type's name (e.g. "EOL"), V2 is "ipns-signature:" + the CBOR data.
+func recordSigningBytes(rec *ipns_pb.IpnsEntry) ([]byte, []byte) {
+	v1 := bytes.Join([][]byte{
+		rec.GetValue(),
+		rec.GetValidity(),
+		[]byte(fmt.Sprint(rec.GetValidityType())),
+	}, nil)
+
+	if len(rec.GetData()) == 0 {
+		return v1, nil
+	}
+	v2 := append([]byte(ipnsSignatureV2Prefix), rec.GetData()...)
+	return v1, v2
+}
+
+// printSigningBytes prints the signing inputs of the record encoded with outputBase
+func printSigningBytes(data []byte, outputBase string, compact bool) error {
+	rec, err := unmarshalIPNSRecord(data)
+	if err != nil {
+		return err
+	}
+
+	enc, err := multibase.EncoderByName(outputBase)
+	if err != nil {
+		return err
+	}
+
+	v1, v2 := recordSigningBytes(rec)
+	out := signingBytes{V1: enc.Encode(v1)}
+	if v2 != nil {
+		s := enc.Encode(v2)
+		out.V2 = &s
+	}
+	return printJSON(out, compact)
+}
+
+// pubKeyFromName recovers the public key inlined in an IPNS name and writes it to stdout in the format.
+// format may be libp2p (the protobuf encoded key), raw (the bare key bytes), or pem (PKIX), the first two are encoded with outputBase.
+func pubKeyFromName(name, format, outputBase string) error {
+	pid, err := decodeIPNSName(name)
+	if err != nil {
+		return err
+	}
+
+	pub, err := pid.ExtractPublicKey()
+	if errors.Is(err, peer.ErrNoPublicKey) {
+		return fmt.Errorf("the public key of %s is hashed rather than inlined in the name (e.g. RSA keys), it can't be recovered from the name alone", name)
+	}
+	if err != nil {
+		return err
+	}
+
+	out, err := marshalPubKey(pub, format)
+	if err != nil {
+		return err
+	}
+
+	if format == "pem" {
+		outputBase = ""
+	}
+	return writeOutput(out, outputBase)
+}
+
+// marshalPubKey encodes the public key in the format, which may be libp2p (the protobuf encoded key), raw (the bare key bytes), or pem (PKIX)
+func marshalPubKey(pub crypto.PubKey, format string) ([]byte, error) {
+	switch format {
+	case "libp2p":
+		return crypto.MarshalPublicKey(pub)
+	case "raw":
+		return pub.Raw()
+	case "pem":
+		stdKey, err := crypto.PubKeyToStdKey(pub)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKIXPublicKey(stdKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s keys have no PEM encoding: %w", pub.Type(), err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+	default:
+		return nil, fmt.Errorf("unknown public key format %q, may be: libp2p, raw, or pem", format)
+	}
+}
+
+// writeEmbeddedPubKey writes the public key embedded in the record to path in the format, see marshalPubKey.
+// Records without an embedded key are skipped with a note on stderr.
+func writeEmbeddedPubKey(rec *ipns_pb.IpnsEntry, path, format string) error {
+	if len(rec.PubKey) == 0 {
+		fmt.Fprintf(os.Stderr, "note: the record has no embedded public key, not writing %s\n", path)
+		return nil
+	}
+
+	pub, err := crypto.UnmarshalPublicKey(rec.PubKey)
+	if err != nil {
+		return fmt.Errorf("could not unmarshal the embedded public key: %w", err)
+	}
+	out, err := marshalPubKey(pub, format)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, out, 0644)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// prompter asks questions on stderr and reads the answers from stdin, so stdout is left for the output
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func newPrompter() *prompter {
+	return &prompter{in: bufio.NewReader(os.Stdin), out: os.Stderr}
+}
+
+// prompt asks for a line of input, returning def if the answer is empty
+func (p *prompter) prompt(label, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", label, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", label)
+	}
+
+	line, err := p.in.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", fmt.Errorf("could not read %s: %w", strings.ToLower(label), err)
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return def, nil
+	}
+	return line, nil
+}
+
+// promptHidden asks for input without echoing it when stdin is a terminal, e.g. for encoded private keys
+func (p *prompter) promptHidden(label string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return p.prompt(label, "")
+	}
+
+	fmt.Fprintf(p.out, "%s: ", label)
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(p.out)
+	if err != nil {
+		return "", fmt.Errorf("could not read %s: %w", strings.ToLower(label), err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// confirm asks a yes/no question, anything other than y or yes is a no
+func (p *prompter) confirm(label string) (bool, error) {
+	answer, err := p.prompt(label+" (y/N)", "")
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Encrypted keys are the libp2p protobuf encoded private key sealed with NaCl secretbox,
+// using a key derived from the password with scrypt. The container is laid out as:
+//
+//	magic "IPNSKEY1" (8 bytes) | scrypt log2(N), r, p (1 byte each) | salt (16 bytes) | nonce (24 bytes) | sealed key
+const encryptedKeyMagic = "IPNSKEY1"
+
+const (
+	encryptedKeyLogN     = 15
+	encryptedKeyR        = 8
+	encryptedKeyP        = 1
+	encryptedKeySaltLen  = 16
+	encryptedKeyNonceLen = 24
+	// encryptedKeyMaxLogN bounds the scrypt cost read from a container so a crafted file can't exhaust memory.
+	// scrypt allocates 128*r*N bytes, so r and p must also be the values encryptKey writes.
+	encryptedKeyMaxLogN = 22
+)
+
+// isEncryptedKey returns whether data is an encrypted key container
+func isEncryptedKey(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedKeyMagic))
+}
+
+// encryptKey seals the protobuf encoded private key with the password
+func encryptKey(keyBytes []byte, password string) ([]byte, error) {
+	if password == "" {
+		return nil, errors.New("cannot encrypt a key with an empty password")
+	}
+
+	header := append([]byte(encryptedKeyMagic), encryptedKeyLogN, encryptedKeyR, encryptedKeyP)
+	salt := make([]byte, encryptedKeySaltLen)
+	var nonce [encryptedKeyNonceLen]byte
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return nil, err
+	}
+
+	boxKey, err := encryptedKeyBoxKey(password, salt, encryptedKeyLogN, encryptedKeyR, encryptedKeyP)
+	if err != nil {
+		return nil, err
+	}
+	out := append(append(header, salt...), nonce[:]...)
+	return secretbox.Seal(out, keyBytes, &nonce, boxKey), nil
+}
+
+// decryptKey opens an encrypted key container, returning the protobuf encoded private key
+func decryptKey(data []byte, password string) ([]byte, error) {
+	headerLen := len(encryptedKeyMagic) + 3
+	if !isEncryptedKey(data) || len(data) < headerLen+encryptedKeySaltLen+encryptedKeyNonceLen+secretbox.Overhead {
+		return nil, errors.New("not an encrypted key or the encrypted key is truncated")
+	}
+
+	logN, r, p := data[len(encryptedKeyMagic)], data[len(encryptedKeyMagic)+1], data[len(encryptedKeyMagic)+2]
+	if logN == 0 || logN > encryptedKeyMaxLogN {
+		return nil, fmt.Errorf("encrypted key has an unsupported scrypt cost 2^%d", logN)
+	}
+	if r != encryptedKeyR || p != encryptedKeyP {
+		return nil, fmt.Errorf("encrypted key has unsupported scrypt parameters r=%d p=%d", r, p)
+	}
+	salt := data[headerLen : headerLen+encryptedKeySaltLen]
+	var nonce [encryptedKeyNonceLen]byte
+	copy(nonce[:], data[headerLen+encryptedKeySaltLen:])
+	sealed := data[headerLen+encryptedKeySaltLen+encryptedKeyNonceLen:]
+
+	boxKey, err := encryptedKeyBoxKey(password, salt, logN, r, p)
+	if err != nil {
+		return nil, err
+	}
+	keyBytes, ok := secretbox.Open(nil, sealed, &nonce, boxKey)
+	if !ok {
+		return nil, errors.New("could not decrypt the key, the password is wrong or the file is corrupted")
+	}
+	return keyBytes, nil
+}
+
+// encryptedKeyBoxKey derives the secretbox key from the password with scrypt
+func encryptedKeyBoxKey(password string, salt []byte, logN, r, p byte) (*[32]byte, error) {
+	k, err := scrypt.Key([]byte(password), salt, 1<<logN, int(r), int(p), 32)
+	if err != nil {
+		return nil, err
+	}
+	var boxKey [32]byte
+	copy(boxKey[:], k)
+	return &boxKey, nil
+}
+
+// keyPassword returns a function reading the password of an encrypted key from the command's --key-password,
+// the environment variable named by --key-password-env, or, when stdin is a terminal, a prompt.
+// It is only called once a key turns out to be encrypted.
+func keyPassword(c *cli.Context) func() (string, error) {
+	return func() (string, error) {
+		if c.IsSet("key-password") && c.IsSet("key-password-env") {
+			return "", errors.New("cannot use --key-password and --key-password-env together, choose one")
+		}
+		if c.IsSet("key-password") {
+			return c.String("key-password"), nil
+		}
+		if name := c.String("key-password-env"); name != "" {
+			password, ok := os.LookupEnv(name)
+			if !ok {
+				return "", fmt.Errorf("environment variable %s passed with --key-password-env is not set", name)
+			}
+			return password, nil
+		}
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return "", errors.New("the key is encrypted, pass its password with --key-password-env or --key-password")
+		}
+		return newPrompter().promptHidden("Key password")
+	}
+}
+
+// addKeyPasswordFlags adds the encrypted key password flags to every command that reads a private key file
+func addKeyPasswordFlags(commands []*cli.Command) {
+	for _, cmd := range commands {
+		addKeyPasswordFlags(cmd.Subcommands)
+		if !hasFlag(cmd, "key-file") && !hasFlag(cmd, "new-key") {
+			continue
+		}
+
+		cmd.Flags = append(cmd.Flags,
+			&cli.StringFlag{
+				Required: false,
+				Name:     "key-password",
+				Usage:    "password of an encrypted key, visible to other users of the machine so prefer --key-password-env",
+			},
+			&cli.StringFlag{
+				Required: false,
+				Name:     "key-password-env",
+				Usage:    "name of an environment variable holding the password of an encrypted key",
+			},
+		)
+	}
+}
+
+// writeKeyFile writes the key to out, or stdout without out, encrypted with the password unless it is nil
+func writeKeyFile(priv crypto.PrivKey, out string, password func() (string, error)) error {
+	keyBytes, err := crypto.MarshalPrivateKey(priv)
+	if err != nil {
+		return err
+	}
+	if password != nil {
+		pw, err := password()
+		if err != nil {
+			return err
+		}
+		if keyBytes, err = encryptKey(keyBytes, pw); err != nil {
+			return err
+		}
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(keyBytes)
+		return err
+	}
+	return os.WriteFile(out, keyBytes, 0600)
+}
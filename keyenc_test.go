@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+)
+
+func TestEncryptDecryptKey(t *testing.T) {
+	keyBytes := []byte("protobuf encoded private key")
+	sealed, err := encryptKey(keyBytes, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isEncryptedKey(sealed) {
+		t.Fatal("the container doesn't start with the magic")
+	}
+	if bytes.Contains(sealed, keyBytes) {
+		t.Fatal("the container holds the key in the clear")
+	}
+
+	got, err := decryptKey(sealed, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, keyBytes) {
+		t.Fatalf("decrypted %q, want %q", got, keyBytes)
+	}
+
+	again, err := encryptKey(keyBytes, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(again, sealed) {
+		t.Fatal("encrypting twice gave the same container, the salt and nonce must be random")
+	}
+}
+
+func TestDecryptKeyErrors(t *testing.T) {
+	sealed, err := encryptKey([]byte("key"), "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	headerLen := len(encryptedKeyMagic) + 3
+
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-1] ^= 1
+	costly := append([]byte{}, sealed...)
+	costly[len(encryptedKeyMagic)] = encryptedKeyMaxLogN + 1
+	largeR := append([]byte{}, sealed...)
+	largeR[len(encryptedKeyMagic)] = encryptedKeyMaxLogN
+	largeR[len(encryptedKeyMagic)+1] = 255
+	largeP := append([]byte{}, sealed...)
+	largeP[len(encryptedKeyMagic)+2] = 255
+
+	tests := []struct {
+		name     string
+		data     []byte
+		password string
+	}{
+		{"wrong password", sealed, "wrong"},
+		{"tampered", tampered, "password"},
+		{"truncated", sealed[:headerLen+encryptedKeySaltLen], "password"},
+		{"scrypt cost too high", costly, "password"},
+		{"scrypt r too high", largeR, "password"},
+		{"scrypt p too high", largeP, "password"},
+		{"not a container", []byte("not an encrypted key at all, just some bytes"), "password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decryptKey(tt.data, tt.password); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestEncryptKeyEmptyPassword(t *testing.T) {
+	if _, err := encryptKey([]byte("key"), ""); err == nil {
+		t.Fatal("expected an error for an empty password")
+	}
+}
+
+func TestLoadEncryptedKeyFile(t *testing.T) {
+	priv, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes, err := crypto.MarshalPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := encryptKey(keyBytes, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, sealed, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := loadPrivateKey(path, "", func() (string, error) { return "password", nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equals(priv) {
+		t.Fatal("the loaded key is not the original key")
+	}
+
+	errNoPassword := errors.New("no password")
+	if _, err := loadPrivateKey(path, "", func() (string, error) { return "", errNoPassword }); !errors.Is(err, errNoPassword) {
+		t.Fatalf("got error %v, want the password error", err)
+	}
+}
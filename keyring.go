@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+type keyringEntry struct {
+	File    string
+	KeyType string
+	Private bool
+	Name    string
+}
+
+// readKeyringEntry reads a libp2p private or public key file
+func readKeyringEntry(path string) (keyringEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return keyringEntry{}, err
+	}
+
+	entry := keyringEntry{File: filepath.Base(path)}
+	var pub crypto.PubKey
+	if priv, err := unmarshalPrivateKey(data); err == nil {
+		pub = priv.GetPublic()
+		entry.Private = true
+	} else if pub, err = crypto.UnmarshalPublicKey(data); err != nil {
+		return keyringEntry{}, fmt.Errorf("not a libp2p key")
+	}
+
+	pid, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		return keyringEntry{}, err
+	}
+
+	entry.KeyType = pub.Type().String()
+	entry.Name = peer.ToCid(pid).String()
+	return entry, nil
+}
+
+// sortKeyring sorts the entries by name (the IPNS name), type, or file, ties keep file name order
+func sortKeyring(entries []keyringEntry, by string) error {
+	var less func(a, b keyringEntry) bool
+	switch by {
+	case "name":
+		less = func(a, b keyringEntry) bool { return a.Name < b.Name }
+	case "type":
+		less = func(a, b keyringEntry) bool { return a.KeyType < b.KeyType }
+	case "file":
+		less = func(a, b keyringEntry) bool { return a.File < b.File }
+	default:
+		return fmt.Errorf("cannot sort keys by %q, may be: name, type, or file", by)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return less(entries[i], entries[j]) })
+	return nil
+}
+
+// listKeyring prints every libp2p key in dir sorted by sortBy (see sortKeyring), files that are not keys are skipped with a warning.
+// compact is only used with asJSON.
+func listKeyring(dir string, asJSON bool, sortBy string, compact bool) error {
+	if dir == "" {
+		return fmt.Errorf("no directory specified")
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	entries := []keyringEntry{}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		path := filepath.Join(dir, f.Name())
+		entry, err := readKeyringEntry(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skipping %s: %v\n", path, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := sortKeyring(entries, sortBy); err != nil {
+		return err
+	}
+
+	if asJSON {
+		return printJSON(entries, compact)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tTYPE\tPRIVATE\tNAME")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\n", e.File, e.KeyType, e.Private, colorize(e.Name, colorKey))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/multiformats/go-multibase"
+)
+
+// kuboKeyFilenamePrefix is the prefix Kubo uses for the files in its keystore
+const kuboKeyFilenamePrefix = "key_"
+
+// kuboKeyFilename encodes a key name the same way Kubo's keystore does, base32 without the multibase prefix
+func kuboKeyFilename(name string) (string, error) {
+	encoded, err := multibase.Encode(multibase.Base32, []byte(name))
+	if err != nil {
+		return "", err
+	}
+	return kuboKeyFilenamePrefix + encoded[1:], nil
+}
+
+// expandHome replaces a leading ~ in the path with the user's home directory
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
+// importKuboKey writes the key into the keystore of the Kubo repo, refusing to overwrite an existing key with the same name
+func importKuboKey(repoPath, name string, priv crypto.PrivKey) error {
+	switch name {
+	case "":
+		return errors.New("importing a key into Kubo requires a name, pass it with --key-name")
+	case "self":
+		return errors.New("cannot import a key named self, it is reserved for the Kubo node's identity")
+	}
+
+	repoPath, err := expandHome(repoPath)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(filepath.Join(repoPath, "config")); err != nil {
+		return fmt.Errorf("%s does not look like a Kubo repo: %w", repoPath, err)
+	}
+
+	keystore := filepath.Join(repoPath, "keystore")
+	if err := os.MkdirAll(keystore, 0700); err != nil {
+		return err
+	}
+
+	filename, err := kuboKeyFilename(name)
+	if err != nil {
+		return err
+	}
+
+	keyBytes, err := crypto.MarshalPrivateKey(priv)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(keystore, filename), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0400)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("a key named %q already exists in %s", name, keystore)
+		}
+		return err
+	}
+
+	log.Infow("importing key into Kubo keystore", "name", name, "file", f.Name())
+	if _, err := f.Write(keyBytes); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// unmarshalPrivateKey reads a private key in either format ipfs key export writes: libp2p-protobuf-cleartext,
+// the default and the same bytes as Kubo's keystore files, or pem-pkcs8-cleartext, a PEM PRIVATE KEY block.
+func unmarshalPrivateKey(data []byte) (crypto.PrivKey, error) {
+	priv, protoErr := crypto.UnmarshalPrivateKey(data)
+	if protoErr == nil {
+		return priv, nil
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("not a libp2p private key (as written by ipfs key export) or a PEM key: %w", protoErr)
+	}
+	if block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("the PEM block type is %s, only PKCS #8 PRIVATE KEY blocks (as written by ipfs key export --format pem-pkcs8-cleartext) are supported", block.Type)
+	}
+	stdKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the PKCS #8 key: %w", err)
+	}
+	// x509 returns Ed25519 keys by value but libp2p only converts pointers to them, Kubo does the same when importing
+	if k, ok := stdKey.(ed25519.PrivateKey); ok {
+		stdKey = &k
+	}
+	priv, _, err = crypto.KeyPairFromStdKey(stdKey)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported PKCS #8 key: %w", err)
+	}
+	return priv, nil
+}
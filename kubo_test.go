@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestUnmarshalPrivateKeyKuboExport(t *testing.T) {
+	tests := []struct {
+		file string
+		name string
+	}{
+		{"kubo-export-ed25519.key", "12D3KooWMQ8Nu7gnLsVdXMjjkQih9weUWszwQQ2MiiFXHWCbqT7h"},
+		{"kubo-export-ed25519.pem", "12D3KooWMQ8Nu7gnLsVdXMjjkQih9weUWszwQQ2MiiFXHWCbqT7h"},
+		{"kubo-export-rsa.key", "QmdygNbVyLEXiekrk6rYNi3hCaN7ZPNtr6BYQaJik2cKHY"},
+		{"kubo-export-rsa.pem", "QmdygNbVyLEXiekrk6rYNi3hCaN7ZPNtr6BYQaJik2cKHY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			priv, err := unmarshalPrivateKey(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			id, err := peer.IDFromPrivateKey(priv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := id.Pretty(); got != tt.name {
+				t.Errorf("got peer ID %s, want %s", got, tt.name)
+			}
+		})
+	}
+}
+
+func TestUnmarshalPrivateKeyRejectsOtherPEM(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "kubo-export-rsa.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	block.Type = "RSA PRIVATE KEY"
+	if _, err := unmarshalPrivateKey(pem.EncodeToMemory(block)); err == nil {
+		t.Fatal("expected an error for a PEM block that isn't PKCS #8")
+	}
+	if _, err := unmarshalPrivateKey([]byte("not a key")); err == nil {
+		t.Fatal("expected an error for data that isn't a key")
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	logging "github.com/ipfs/go-log/v2"
+
+	"github.com/urfave/cli/v2"
+)
+
+var log = logging.Logger("ipns-utils")
+
+// setupLogging enables info logging to stderr for --verbose and debug logging for --vv.
+// Without either flag the logging configuration from the GOLOG_* environment variables is left alone.
+func setupLogging(c *cli.Context) error {
+	var level string
+	switch {
+	case c.Bool("vv"):
+		level = "debug"
+	case c.Bool("verbose"):
+		level = "info"
+	default:
+		return nil
+	}
+
+	logging.SetupLogging(logging.Config{
+		Format: logging.PlaintextOutput,
+		Stderr: true,
+		Level:  logging.LevelError,
+	})
+	return logging.SetLogLevel("ipns-utils", level)
+}
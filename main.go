@@ -1,12 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto/rand"
-	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	crypto_pb "github.com/libp2p/go-libp2p-core/crypto/pb"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/libp2p/go-libp2p-core/crypto"
@@ -24,229 +31,2315 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
+var gzipMagic = []byte{0x1f, 0x8b}
+
 func main() {
 	var ipnsKey, topic string
 	var cidVersion int
 
-	app := &cli.App{
-		Name: "ipns-utils",
-		Commands: []*cli.Command{
-			{
-				Name:  "create",
-				Usage: "create a IPNS records",
-				Subcommands: []*cli.Command{
+	app := &cli.App{
+		Name: "ipns-utils",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Required: false,
+				Name:     "verbose",
+				Aliases:  []string{"v"},
+				Usage:    "log what the tool is doing to stderr",
+			},
+			&cli.BoolFlag{
+				Required: false,
+				Name:     "vv",
+				Usage:    "log what the tool is doing to stderr, including debug details",
+			},
+			&cli.PathFlag{
+				Required: false,
+				Name:     "config",
+				Value:    defaultConfigPath,
+				Usage:    "YAML file of default flag values, flags passed on the command line take precedence",
+			},
+			&cli.StringFlag{
+				Required: false,
+				Name:     "color",
+				Value:    "auto",
+				Usage:    "color table output, may be: auto (when stdout is a terminal and NO_COLOR isn't set), always, or never",
+			},
+			&cli.BoolFlag{
+				Required: false,
+				Name:     "no-color",
+				Usage:    "same as --color never",
+			},
+		},
+		Before: func(c *cli.Context) error {
+			if err := setupColor(c.String("color"), c.Bool("no-color")); err != nil {
+				return err
+			}
+			return setupLogging(c)
+		},
+		Commands: []*cli.Command{
+			{
+				Name:  "create",
+				Usage: "create a IPNS records",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "id",
+						Usage:     "create an IPNS identifier",
+						UsageText: "generate a private key and its IPNS name. By default the key is written to stdout (raw bytes unless --output-base is set) and the name to stderr, --stdout and --stderr choose otherwise",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Required: false,
+								Name:     "stdout",
+								Value:    "key",
+								Usage:    "what to write to stdout, may be: key or name",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "stderr",
+								Value:    "name",
+								Usage:    "what to write to stderr, may be: name, key, or none",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "name-base",
+								Value:    "",
+								Usage:    "multibase to encode the name in, e.g. base36. Defaults to base32",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "bare-name",
+								Usage:    "write the name on stderr without the \"identifier: \" label, so scripts can capture it as is",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "output-base",
+								Value:    "",
+								Usage:    "multibase name or prefix character, none means raw bytes. identity is raw bytes with the 0x00 multibase prefix",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "type",
+								Value:    "ed25519",
+								Usage:    "type of the key to create",
+							},
+							&cli.IntFlag{
+								Required: false,
+								Name:     "size",
+								Value:    -1,
+								Usage:    "size of the key to generate (only valid to be set for RSA keys which defaults to 2048)",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "kubo-import",
+								Usage:    "also write the key into the keystore of the Kubo repo at --ipfs-path",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "ipfs-path",
+								Value:    "~/.ipfs",
+								EnvVars:  []string{"IPFS_PATH"},
+								Usage:    "path to the Kubo repo used by --kubo-import",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "key-name",
+								Usage:    "name of the key in the Kubo keystore, as shown by ipfs key list",
+							},
+							&cli.IntFlag{
+								Required: false,
+								Name:     "shares",
+								Usage:    "instead of writing the key, split it into this many shares with Shamir's Secret Sharing, rebuild it with reconstruct-key",
+							},
+							&cli.IntFlag{
+								Required: false,
+								Name:     "threshold",
+								Usage:    "how many of the --shares are needed to reconstruct the key",
+							},
+							&cli.PathFlag{
+								Required: false,
+								Name:     "share-dir",
+								Value:    ".",
+								Usage:    "directory the --shares are written to, as <name>.share-<i>-of-<n>.json with mode 0600",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							if c.IsSet("shares") || c.IsSet("threshold") {
+								return createSharedID(c)
+							}
+							stdout, stderr := c.String("stdout"), c.String("stderr")
+							if stdout == stderr {
+								return fmt.Errorf("--stdout and --stderr are both %s, choose different streams for the key and the name", stdout)
+							}
+							if stdout != "key" && stderr != "key" && !c.Bool("kubo-import") {
+								return errors.New("the key would not be written anywhere, pass --stdout key, --stderr key, or --kubo-import")
+							}
+
+							priv, err := generateKey(c.String("type"), c.Int("size"))
+							if err != nil {
+								return err
+							}
+
+							if c.Bool("kubo-import") {
+								if err := importKuboKey(c.String("ipfs-path"), c.String("key-name"), priv); err != nil {
+									return err
+								}
+							}
+
+							return createIPNSID(priv, c.String("output-base"), c.String("name-base"), stdout, stderr, c.Bool("bare-name"))
+						},
+					},
+					{
+						Name:      "record",
+						Usage:     "record <value>",
+						UsageText: "create an IPNS record",
+						Flags: []cli.Flag{
+							&cli.PathFlag{
+								Required: false,
+								Name:     "key-file",
+								Value:    "",
+								Usage:    "The path to the private key",
+							},
+							&cli.PathFlag{
+								Required: false,
+								Name:     "key-encoded",
+								Value:    "",
+								Usage:    "multibase encoded private key",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "name",
+								Aliases:  []string{"n"},
+								Usage:    "the IPNS name the record is expected to be for, creating the record fails if the key is for a different name",
+							},
+							&cli.PathFlag{
+								Required: false,
+								Name:     "pkcs11-module",
+								Usage:    "sign with a key in an HSM through this PKCS#11 module (e.g. /usr/lib/softhsm/libsofthsm2.so), the private key never leaves it. Needs a build with -tags pkcs11",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "pkcs11-label",
+								Usage:    "label of the key in the PKCS#11 module, the public key must have the same label",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "pkcs11-pin-env",
+								Value:    "PKCS11_PIN",
+								Usage:    "environment variable holding the PKCS#11 user PIN, it is asked for when the variable is not set",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "key-env",
+								Usage:    "name of an environment variable holding the multibase encoded private key, e.g. a CI secret",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "output-base",
+								Value:    "",
+								Usage:    "multibase name or prefix character, none means raw bytes. identity is raw bytes with the 0x00 multibase prefix. Use base64url for text safe to pass to HTTP tooling",
+							},
+							&cli.GenericFlag{
+								Required: false,
+								Name:     "ttl",
+								Value:    &durationValue{},
+								Usage:    "how long resolvers may cache the record. When not set the protobuf TTL field is left out, which is different from an explicit 0. The V2 signed data always has a TTL, 0 when not set",
+							},
+							&cli.TimestampFlag{
+								Required:    false,
+								Name:        "eol",
+								Layout:      "2006-01-02T15:04:05",
+								DefaultText: "End of life for the record, in UTC. Time format is 2006-01-02T15:04:05. Defaults to 24 hours from now",
+							},
+							&cli.GenericFlag{
+								Required:    false,
+								Name:        "lifetime",
+								Value:       &durationValue{},
+								DefaultText: "An alternative to eol. Defines how long from now a record should be valid for (e.g. 30s, -10m, 24.5h, 7d). Defaults to 24 hours",
+							},
+							&cli.GenericFlag{
+								Required: false,
+								Name:     "warn-short-lifetime",
+								Value:    &durationValue{d: defaultMinLifetime},
+								Usage:    "warn when the record would be valid for less than this (e.g. a mistyped --lifetime), 0 turns the check off",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "strict",
+								Usage:    "fail instead of warning when the record would be valid for less than --warn-short-lifetime",
+							},
+							&cli.Int64Flag{
+								Required: false,
+								Name:     "seqno",
+								Value:    0,
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "value",
+								Value:    "/ipfs/bafkqaaa",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "from-add",
+								Usage:    "read the value from ipfs add output on stdin, e.g. ipfs add -Q <file> | ipns-utils create record --from-add",
+							},
+							&cli.PathFlag{
+								Required: false,
+								Name:     "base-record",
+								Value:    "",
+								Usage:    "path to an existing record whose fields are used as defaults, the seqno is incremented unless set",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "check-value",
+								Usage:    "before signing, check the value can be retrieved from the gateway passed with --gateway",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "gateway",
+								Usage:    "URL of the gateway used by --check-value, e.g. https://example.com",
+							},
+							&cli.GenericFlag{
+								Required: false,
+								Name:     "check-timeout",
+								Value:    &durationValue{d: 30 * time.Second},
+								Usage:    "how long to wait for the gateway when using --check-value",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "interactive",
+								Aliases:  []string{"i"},
+								Usage:    "prompt for the value, lifetime, and key when they aren't passed as flags, and confirm before creating the record",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "http-response",
+								Usage:    "output the record as the HTTP response to GET /routing/v1/ipns/{name}, including the content type and caching headers",
+							},
+							&cli.StringSliceFlag{
+								Required: false,
+								Name:     "extra-field",
+								Usage:    "add a non-standard key=value string field to the record's CBOR data, may be repeated",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "stats",
+								Usage:    "write the key type, signing time, and size of the record to stderr as a line of JSON",
+							},
+							&cli.PathFlag{
+								Required: false,
+								Name:     "stats-file",
+								Usage:    "append the --stats line to this file instead of stderr, so the stats of many runs can be aggregated",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "ttl-from-eol",
+								Usage:    "set the TTL to the record's whole lifetime (EOL minus now) so it's cached for as long as it's valid, capped at 7d",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "sign-only",
+								Usage:    "sign record fields built elsewhere exactly as given, from --partial-record and/or --value, --validity, --seqno, and --ttl",
+							},
+							&cli.PathFlag{
+								Required: false,
+								Name:     "partial-record",
+								Usage:    "with --sign-only, an unsigned record to take the fields from, flags that are set override its fields",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "validity",
+								Usage:    "with --sign-only, the EOL validity exactly as it should appear in the record, e.g. 2030-01-01T00:00:00.000000000Z",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "minimal",
+								Usage:    "create the smallest valid record for testing resolvers: no protobuf TTL, no V1 signature, and no embedded public key unless the name can't inline it",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "maximal",
+								Usage:    "create a record with every optional field for testing resolvers: both signatures, a TTL (1h unless --ttl is set), and the public key embedded even when the name inlines it",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Hidden:   true,
+								Name:     "corrupt",
+								Usage:    "produce an intentionally invalid record for testing validators, may be: signature, seqno, eol, or value",
+							},
+							&cli.IntFlag{
+								Required: false,
+								Hidden:   true,
+								Name:     "validity-type",
+								Value:    int(ipns_pb.IpnsEntry_EOL),
+								Usage:    "validity type to sign the record with, for testing how resolvers handle types other than EOL (0)",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							if c.Bool("sign-only") {
+								return signOnly(c)
+							}
+
+							seqno := c.Int64("seqno")
+							// the TTL is left out of the record unless it's set, an explicit 0 is kept
+							var ttl *time.Duration
+							if c.IsSet("ttl") {
+								ttl = &c.Generic("ttl").(*durationValue).d
+							}
+							eol := c.Timestamp("eol")
+							const lifetimeStr = "lifetime"
+							lifetime := c.Generic(lifetimeStr).(*durationValue).d
+							lifetimeSet := c.IsSet(lifetimeStr)
+							value := c.String("value")
+							keyFile := c.Path("key-file")
+							keyEncoded, err := encodedKey(c)
+							if err != nil {
+								return err
+							}
+
+							if c.IsSet(lifetimeStr) && eol != nil {
+								return errors.New("cannot define lifetime and eol on a record, choose one")
+							}
+							if c.Bool("from-add") {
+								if c.IsSet("value") || c.Bool("interactive") {
+									return errors.New("cannot use --from-add with --value or --interactive, the value is read from stdin")
+								}
+								if value, err = valueFromAddOutput(os.Stdin); err != nil {
+									return err
+								}
+							}
+
+							if baseRecord := c.Path("base-record"); baseRecord != "" {
+								base, err := readIPNSRecordFile(baseRecord, "auto")
+								if err != nil {
+									return err
+								}
+								if !c.IsSet("seqno") {
+									seqno = int64(base.GetSequence() + 1)
+								}
+								if !c.IsSet("ttl") && base.Ttl != nil {
+									baseTTL := time.Duration(base.GetTtl())
+									ttl = &baseTTL
+								}
+								if !c.IsSet("value") {
+									value = string(base.GetValue())
+								}
+								if !c.IsSet(lifetimeStr) && eol == nil {
+									baseEOL, err := ipns.GetEOL(base)
+									if err != nil {
+										return err
+									}
+									eol = &baseEOL
+								}
+							}
+
+							var p *prompter
+							if c.Bool("interactive") {
+								p = newPrompter()
+								var err error
+								if !c.IsSet("value") {
+									if value, err = p.prompt("Value", value); err != nil {
+										return err
+									}
+								}
+								if !lifetimeSet && eol == nil {
+									l, err := p.prompt("Lifetime", "24h")
+									if err != nil {
+										return err
+									}
+									if lifetime, err = parseDuration(l); err != nil {
+										return err
+									}
+									lifetimeSet = true
+								}
+								if keyFile == "" && keyEncoded == "" && !c.IsSet("pkcs11-module") {
+									if keyFile, err = p.prompt("Key file (leave empty to enter an encoded key)", ""); err != nil {
+										return err
+									}
+									if keyFile == "" {
+										if keyEncoded, err = p.promptHidden("Encoded key"); err != nil {
+											return err
+										}
+									}
+								}
+							}
+
+							if !lifetimeSet && eol == nil {
+								eolTime := time.Now().Add(time.Hour * 24)
+								eol = &eolTime
+							} else if lifetimeSet {
+								eolTime := time.Now().Add(lifetime)
+								eol = &eolTime
+							}
+							if minLifetime := c.Generic("warn-short-lifetime").(*durationValue).d; minLifetime > 0 {
+								if err := checkMinLifetime(*eol, minLifetime); err != nil {
+									if c.Bool("strict") {
+										return err
+									}
+									fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+								}
+							} else if c.Bool("strict") {
+								return errors.New("--strict needs a --warn-short-lifetime threshold, it cannot be 0")
+							}
+
+							var validityType *ipns_pb.IpnsEntry_ValidityType
+							if c.IsSet("validity-type") {
+								if c.IsSet("extra-field") {
+									return errors.New("cannot use --extra-field with --validity-type, the record is re-signed with only the standard fields")
+								}
+								typ := ipns_pb.IpnsEntry_ValidityType(c.Int("validity-type"))
+								validityType = &typ
+							}
+
+							fieldSet, err := fieldSetFlag(c.Bool("minimal"), c.Bool("maximal"))
+							if err != nil {
+								return err
+							}
+							switch fieldSet {
+							case "minimal":
+								for _, f := range []string{"ttl", "ttl-from-eol", "extra-field"} {
+									if c.IsSet(f) {
+										return fmt.Errorf("cannot use --%s with --minimal, the record leaves it out", f)
+									}
+								}
+								ttl = nil
+							case "maximal":
+								if ttl == nil && !c.Bool("ttl-from-eol") {
+									d := maximalRecordTTL
+									ttl = &d
+								}
+							}
+
+							if c.Bool("ttl-from-eol") {
+								if c.IsSet("ttl") {
+									return errors.New("cannot use --ttl and --ttl-from-eol together, choose one")
+								}
+								d, err := ttlFromEOL(*eol)
+								if err != nil {
+									return err
+								}
+								ttl = &d
+							}
+
+							var key crypto.PrivKey
+							if module := c.Path("pkcs11-module"); module != "" {
+								if keyFile != "" || keyEncoded != "" {
+									return errors.New("cannot use a PKCS#11 key together with a key file or encoded key")
+								}
+								key, err = loadPKCS11KeyFlags(c, module)
+							} else {
+								key, err = loadPrivateKey(keyFile, keyEncoded, keyPassword(c))
+							}
+							if err != nil {
+								return err
+							}
+
+							if p != nil {
+								pid, err := peer.IDFromPrivateKey(key)
+								if err != nil {
+									return err
+								}
+								fmt.Fprintf(os.Stderr, "IPNS name: %s\nValue: %s\nSequence number: %d\nEOL: %s\n", peer.ToCid(pid), value, seqno, eol.UTC().Format(time.RFC3339))
+								ok, err := p.confirm("Create this record?")
+								if err != nil {
+									return err
+								}
+								if !ok {
+									return errors.New("record creation cancelled")
+								}
+							}
+
+							if c.Bool("check-value") {
+								if !c.IsSet("gateway") {
+									return errors.New("checking the value requires a gateway, pass it with --gateway")
+								}
+								if err := checkValueOnGateway(c.Context, c.String("gateway"), value, c.Generic("check-timeout").(*durationValue).d); err != nil {
+									return err
+								}
+							}
+
+							if c.Bool("http-response") && c.String("output-base") != "" {
+								return errors.New("cannot use an output base with --http-response")
+							}
+
+							extra, err := parseExtraFields(c.StringSlice("extra-field"))
+							if err != nil {
+								return err
+							}
+
+							var stats io.Writer
+							if statsFile := c.Path("stats-file"); statsFile != "" {
+								f, err := os.OpenFile(statsFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+								if err != nil {
+									return err
+								}
+								defer f.Close()
+								stats = f
+							} else if c.Bool("stats") {
+								stats = os.Stderr
+							}
+
+							if c.IsSet("name") {
+								if err := checkKeyName(c.String("name"), key); err != nil {
+									return err
+								}
+							}
+
+							return createIPNSRecord(seqno, ttl, *eol, value, extra, key, c.String("output-base"), c.Bool("http-response"), stats, validityType, fieldSet, c.String("corrupt"))
+						},
+					},
+					{
+						Name:      "provenance",
+						Usage:     "provenance --key-file <path> <record>",
+						UsageText: "wrap a record in a JSON-LD document with its name, value, sequence number, EOL, creation time, and tool version for archiving, optionally signed by the record's key",
+						Flags: []cli.Flag{
+							&cli.PathFlag{
+								Required: true,
+								Name:     "key-file",
+								Usage:    "the path to the private key that signed the record",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "input-type",
+								Value:    "path",
+								Usage:    "record input type, may be: auto, bytes, multibase, or path",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "sign",
+								Usage:    "sign the document with the key, the signature covers every other field of the document",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "compact",
+								Usage:    "output single line JSON",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							recordBytes, err := readInput(c.Args().First(), c.String("input-type"), "auto", 0)
+							if err != nil {
+								return err
+							}
+							key, err := loadPrivateKey(c.Path("key-file"), "", keyPassword(c))
+							if err != nil {
+								return err
+							}
+							return createProvenance(recordBytes, key, c.Bool("sign"), c.Bool("compact"))
+						},
+					},
+					{
+						Name:      "records",
+						Usage:     "records --manifest <file>",
+						UsageText: "create a batch of IPNS records from a JSON or JSON lines manifest, output in manifest order in the same format as the manifest",
+						Flags: []cli.Flag{
+							&cli.PathFlag{
+								Required: true,
+								Name:     "manifest",
+								Usage:    "path to a JSON array, or JSON lines, of records to create, each with Value, SequenceNumber, and optionally EOL, Lifetime, and TTL",
+							},
+							&cli.PathFlag{
+								Required: false,
+								Name:     "key-file",
+								Value:    "",
+								Usage:    "The path to the private key",
+							},
+							&cli.PathFlag{
+								Required: false,
+								Name:     "key-encoded",
+								Value:    "",
+								Usage:    "multibase encoded private key",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "output-base",
+								Value:    "base64",
+								Usage:    "multibase name or prefix character used to encode each record",
+							},
+							&cli.IntFlag{
+								Required: false,
+								Name:     "concurrency",
+								Value:    1,
+								Usage:    "number of records to sign in parallel",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "progress",
+								Usage:    "periodically report how many records have been signed on stderr",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							key, err := loadPrivateKey(c.Path("key-file"), c.String("key-encoded"), keyPassword(c))
+							if err != nil {
+								return err
+							}
+
+							return createIPNSRecords(c.Path("manifest"), key, c.String("output-base"), c.Int("concurrency"), c.Bool("progress"))
+						},
+					},
+					{
+						Name:      "mapped-records",
+						Usage:     "mapped-records --mapping <file> --out <dir>",
+						UsageText: "create a record for each line of a mapping file of `<name-or-key-file> <value>`, each signed with its own key, and write them to a directory along with a manifest.json listing them",
+						Flags: []cli.Flag{
+							&cli.PathFlag{
+								Required: true,
+								Name:     "mapping",
+								Usage:    "file with lines of `<name-or-key-file> <value>` (or `<name-or-key-file> -> <value>`), blank lines and lines starting with # are ignored. Key files are relative to the mapping file",
+							},
+							&cli.PathFlag{
+								Required: false,
+								Name:     "key-dir",
+								Usage:    "directory of private keys used for lines that give an IPNS name, the key for each name is found by its name",
+							},
+							&cli.PathFlag{
+								Required: true,
+								Name:     "out",
+								Usage:    "directory to write the records (as <name>.ipns-record) and manifest.json to",
+							},
+							&cli.Int64Flag{
+								Required: false,
+								Name:     "seqno",
+								Value:    0,
+								Usage:    "sequence number of every record",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "eol",
+								Usage:    "end of life of every record, in UTC. Time format is 2006-01-02T15:04:05",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "lifetime",
+								Usage:    "an alternative to eol, how long from now every record is valid for (e.g. 30m or 7d). Defaults to 24 hours",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "ttl",
+								Usage:    "how long resolvers may cache the records, when not set the records have no protobuf TTL (the V2 signed data has 0)",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							template := manifestEntry{
+								SequenceNumber: c.Int64("seqno"),
+								EOL:            c.String("eol"),
+								Lifetime:       c.String("lifetime"),
+								TTL:            c.String("ttl"),
+							}
+							return createMappedRecords(c.Path("mapping"), c.Path("key-dir"), c.Path("out"), template)
+						},
+					},
+				},
+			},
+			{
+				Name: "parse",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "record",
+						Usage:     "record <record>",
+						UsageText: "parse an IPNS record. The public key, if present, is multibase encoded",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Required: false,
+								Name:     "input-type",
+								Value:    "auto",
+								Usage:    "record input type, may be: auto, bytes, multibase, path, routing-json (a JSON routing API response with base64 encoded records, read like auto), dag-cbor (a file holding a DAG-CBOR object with the record at --path), or prototext (a file holding the record in protobuf text format)",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "path",
+								Usage:    "with --input-type dag-cbor, the / separated map keys and list indexes leading to the record's bytes, e.g. names/0/record",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "validate",
+								Usage:    "validate the record against the IPNS name passed with --name",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "accept-expired",
+								Usage:    "only check the record is authentic, an expired record is reported but not treated as a failure",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "name",
+								Aliases:  []string{"n"},
+								Usage:    "the IPNS name the record is stored under",
+							},
+							&cli.GenericFlag{
+								Required: false,
+								Name:     "max-lifetime",
+								Value:    &durationValue{},
+								Usage:    "warn, or fail with --validate, if the record's EOL is further than this from now (e.g. 30d)",
+							},
+							&cli.GenericFlag{
+								Required: false,
+								Name:     "max-size",
+								Value:    &sizeValue{},
+								Usage:    "reject records larger than this size (e.g. 10KiB, the limit in the IPNS spec), 0 means no limit",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "decompress",
+								Value:    "auto",
+								Usage:    "decompression applied to records read from files, may be: none, gzip, or auto (gzip when the file has gzip magic bytes or a .gz extension)",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "http-response",
+								Usage:    "the input is an HTTP response to GET /routing/v1/ipns/{name}, parse the record in its body",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "cid-base",
+								Value:    defaultCIDBase,
+								Usage:    "multibase name or prefix character to encode the CID in the record's value with",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "compact",
+								Usage:    "output single line JSON",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "env",
+								Usage:    "output the record's fields as IPNS_VALUE=..., IPNS_SEQNO=..., etc. shell variable assignments for eval or source",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "since-epoch",
+								Usage:    "also output the EOL as RFC3339 and as seconds and nanoseconds since the Unix epoch",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "time-format",
+								Value:    defaultTimeFormat,
+								Usage:    "how to output the EOL, may be: rfc3339 (in UTC), unix (seconds since the epoch), go (Go's default time format, e.g. 2006-01-02 15:04:05.999999999 +0000 UTC), or a Go time layout such as 2006-01-02",
+							},
+							&cli.PathFlag{
+								Required: false,
+								Name:     "pubkey-out",
+								Usage:    "write the record's embedded public key, if it has one, to this file",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "pubkey-format",
+								Value:    "libp2p",
+								Usage:    "format of the key written with --pubkey-out, may be: libp2p, raw, or pem",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "redact",
+								Usage:    "mask the middle of the value, CID, public key, and extra fields so the output can be shared",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "proto-version",
+								Usage:    "also output which optional protobuf fields the record has and the kind of library that likely produced it",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "identify-publisher",
+								Usage:    "also output a guess of whether Kubo or w3name published the record, with the evidence for it",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "table",
+								Usage:    "output the record as a table of fields, colored by --color",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "reconstruct-args",
+								Usage:    "output the create record flags (value, seqno, eol, ttl) that reproduce the record's fields, shell quoted for eval",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "hexdump",
+								Usage:    "instead of parsing the record, output a hex dump of it marking where each protobuf field starts, works on records that fail to unmarshal",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "strict",
+								Usage:    "fail if the record's V2 CBOR data isn't canonical DAG-CBOR, which strict implementations reject",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							inputType := c.String("input-type")
+							switch inputType {
+							case "routing-json":
+								inputType = "auto"
+							case "dag-cbor", "prototext":
+								inputType = "path"
+							}
+							if c.IsSet("path") && c.String("input-type") != "dag-cbor" {
+								return errors.New("--path is only used with --input-type dag-cbor")
+							}
+							// the wrapper formats are larger than the record they hold, so --max-size only bounds decompressing a bare record
+							var maxSize int64
+							if inputType == c.String("input-type") && !c.Bool("http-response") {
+								maxSize = c.Generic("max-size").(*sizeValue).n
+							}
+							recordBytes, err := readInput(c.Args().First(), inputType, c.String("decompress"), maxSize)
+							if err != nil {
+								return err
+							}
+							switch c.String("input-type") {
+							case "dag-cbor":
+								if recordBytes, err = recordFromDAGCBOR(recordBytes, c.String("path")); err != nil {
+									return err
+								}
+							case "prototext":
+								if recordBytes, err = recordFromPrototext(recordBytes); err != nil {
+									return err
+								}
+							}
+							if c.Bool("http-response") {
+								if recordBytes, err = unwrapHTTPResponse(recordBytes); err != nil {
+									return err
+								}
+							}
+							if c.String("input-type") == "routing-json" {
+								records, err := routingJSONRecords(recordBytes)
+								if err != nil {
+									return err
+								}
+								if len(records) > 1 {
+									return parseRoutingJSONRecords(records, c)
+								}
+								recordBytes = records[0]
+							}
+							if err := checkInputSize(recordBytes, c.Generic("max-size").(*sizeValue)); err != nil {
+								return err
+							}
+
+							if c.Bool("hexdump") {
+								return writeHexdump(os.Stdout, recordBytes)
+							}
+
+							if c.Bool("env") && c.Bool("compact") {
+								return errors.New("cannot use --env and --compact together, choose one")
+							}
+							if c.Bool("table") && (c.Bool("env") || c.Bool("compact")) {
+								return errors.New("cannot use --table with --env or --compact, choose one")
+							}
+							if c.Bool("reconstruct-args") {
+								for _, f := range []string{"env", "table", "compact", "redact"} {
+									if c.Bool(f) {
+										return fmt.Errorf("cannot use --reconstruct-args with --%s, choose one", f)
+									}
+								}
+							}
+							parsed, err := decodeIPNSRecord(recordBytes, c.String("cid-base"))
+							if err != nil {
+								return err
+							}
+							applyParseOptions(parsed, c)
+							if c.Bool("reconstruct-args") {
+								printReconstructArgs(parsed)
+							} else if c.Bool("env") {
+								printRecordEnv(parsed)
+							} else if c.Bool("table") {
+								if err := printRecordTable(parsed); err != nil {
+									return err
+								}
+							} else if err := printJSON(parsed, c.Bool("compact")); err != nil {
+								return err
+							}
+
+							rec, err := unmarshalIPNSRecord(recordBytes)
+							if err != nil {
+								return err
+							}
+
+							if c.IsSet("pubkey-out") {
+								if err := writeEmbeddedPubKey(rec, c.Path("pubkey-out"), c.String("pubkey-format")); err != nil {
+									return err
+								}
+							}
+
+							if c.Bool("strict") {
+								if err := checkCanonicalCBOR(rec.GetData()); err != nil {
+									return err
+								}
+							}
+
+							validate := c.Bool("validate")
+							if c.IsSet("max-lifetime") {
+								if err := checkMaxLifetime(rec, c.Generic("max-lifetime").(*durationValue).d); err != nil {
+									if validate {
+										return err
+									}
+									fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+								}
+							}
+
+							if !validate {
+								return nil
+							}
+
+							if !c.IsSet("name") {
+								return errors.New("validating a record requires the IPNS name it is stored under, pass it with --name")
+							}
+							name, err := decodeIPNSName(c.String("name"))
+							if err != nil {
+								return err
+							}
+
+							expired, err := verifyIPNSRecord(name, rec, c.Bool("accept-expired"))
+							if err != nil {
+								return err
+							}
+							if expired {
+								fmt.Fprintln(os.Stderr, "warning: the record has expired, ignored with --accept-expired")
+							}
+							return nil
+						},
+					},
+					{
+						Name:      "records",
+						Usage:     "records <dir or archive>",
+						UsageText: "parse every IPNS record in a directory or tar archive, output as a JSON array sorted by file name unless --sort says otherwise",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Required: false,
+								Name:     "sort",
+								Value:    "name",
+								Usage:    "order of the records, may be: name (the file name), seqno, or eol",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "format",
+								Value:    "json",
+								Usage:    "output format, may be: json or csv (one row per record with its name, value, seqno, eol, ttl, and whether it's valid)",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "summary",
+								Usage:    "output statistics across the records instead of the records: sequence number spread, EOL distribution, how many expired, and how many per key type",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "table",
+								Usage:    "with --summary, output a table instead of JSON",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "input-type",
+								Value:    "dir",
+								Usage:    "where the records are, may be: dir or tar (a tar archive, optionally gzip compressed, entries that aren't records are skipped with a warning)",
+							},
+							&cli.IntFlag{
+								Required: false,
+								Name:     "max-records",
+								Value:    0,
+								Usage:    "with --input-type tar, fail when the archive has more than this many files, 0 means no limit",
+							},
+							&cli.GenericFlag{
+								Required: false,
+								Name:     "max-record-size",
+								Value:    &sizeValue{n: maxTarEntrySize},
+								Usage:    "with --input-type tar, skip entries larger than this (e.g. 64KiB) without reading them",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "fail-fast",
+								Usage:    "stop at the first record that fails",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "continue-on-error",
+								Value:    true,
+								Usage:    "process every record and report a summary of the failures at the end",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "decompress",
+								Value:    "auto",
+								Usage:    "decompression applied to records read from files, may be: none, gzip, or auto (gzip when the file has gzip magic bytes or a .gz extension)",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "cid-base",
+								Value:    defaultCIDBase,
+								Usage:    "multibase name or prefix character to encode the CID in the record's value with",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "compact",
+								Usage:    "output single line JSON",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "redact",
+								Usage:    "mask the middle of the value, CID, public key, and extra fields so the output can be shared",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							failFast, err := failFastMode(c)
+							if err != nil {
+								return err
+							}
+							switch format := c.String("format"); {
+							case format != "json" && format != "csv":
+								return fmt.Errorf("unknown format %q, may be: json or csv", format)
+							case format == "csv" && c.Bool("summary"):
+								return errors.New("--format csv cannot be used with --summary")
+							}
+
+							if c.String("input-type") == "tar" {
+								limits := streamLimits{maxRecords: c.Int("max-records"), maxRecordSize: c.Generic("max-record-size").(*sizeValue).n}
+								results, err := parseTarRecords(c.Args().First(), c.String("decompress"), c.String("cid-base"), limits)
+								if err != nil {
+									return err
+								}
+								for _, r := range results {
+									if c.Bool("redact") {
+										r.Record.redact()
+									}
+								}
+								return outputFileRecords(results, c)
+							} else if c.String("input-type") != "dir" {
+								return fmt.Errorf("unknown input type %q, may be: dir or tar", c.String("input-type"))
+							}
+
+							results := []fileRecord{}
+							err = processDirectory(c.Args().First(), failFast, func(path string) error {
+								recordBytes, err := readInput(path, "path", c.String("decompress"), 0)
+								if err != nil {
+									return err
+								}
+								rec, err := decodeIPNSRecord(recordBytes, c.String("cid-base"))
+								if err != nil {
+									return err
+								}
+								if c.Bool("redact") {
+									rec.redact()
+								}
+								results = append(results, fileRecord{File: path, Record: rec, data: recordBytes})
+								return nil
+							})
+							if outErr := outputFileRecords(results, c); outErr != nil {
+								return outErr
+							}
+							return err
+						},
+					},
+					{
+						Name:      "datastore",
+						Usage:     "datastore <export-file>",
+						UsageText: "parse the IPNS records in a datastore export, a sequence of uvarint length prefixed keys each followed by a uvarint length prefixed value. Entries that are not IPNS records are skipped. Output is a JSON array in the order of the export",
+						Flags: []cli.Flag{
+							&cli.IntFlag{
+								Required: false,
+								Name:     "max-records",
+								Value:    0,
+								Usage:    "fail when the export has more than this many entries, 0 means no limit",
+							},
+							&cli.GenericFlag{
+								Required: false,
+								Name:     "max-record-size",
+								Value:    &sizeValue{n: maxDatastoreFieldSize},
+								Usage:    "fail on length prefixes larger than this (e.g. 64KiB), which usually means the export is corrupt",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "compact",
+								Usage:    "output single line JSON",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							limits := streamLimits{maxRecords: c.Int("max-records"), maxRecordSize: c.Generic("max-record-size").(*sizeValue).n}
+							return parseDatastoreExport(c.Args().First(), limits, c.Bool("compact"))
+						},
+					},
+					{
+						Name:      "key",
+						Usage:     "key <key>",
+						UsageText: "parse the encoded libp2p key format used with IPNS. The key material is multibase encoded",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Required: false,
+								Name:     "input-type",
+								Value:    "auto",
+								Usage:    "record input type, may be: auto, bytes, multibase, or path",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "private-key",
+								Value:    true,
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "fingerprint",
+								Usage:    "only print a short fingerprint of the key. It is derived from the public key alone so it is safe to share",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "compact",
+								Usage:    "output single line JSON",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							keyBytes, err := readInput(c.Args().First(), c.String("input-type"), "none", 0)
+							if err != nil {
+								return err
+							}
+
+							if c.Bool("fingerprint") {
+								return printKeyFingerprint(keyBytes, c.Bool("private-key"))
+							}
+
+							return parselibp2pkey(keyBytes, c.Bool("private-key"), c.Bool("compact"))
+						},
+					},
+				},
+			},
+			{
+				Name:      "dump",
+				Usage:     "dump --key-file <path>",
+				UsageText: "print everything derivable from a key: its type, peer ID, IPNS names, pubsub topic, and DHT keys",
+				Flags: []cli.Flag{
+					&cli.PathFlag{
+						Required: false,
+						Name:     "key-file",
+						Value:    "",
+						Usage:    "The path to the private key",
+					},
+					&cli.PathFlag{
+						Required: false,
+						Name:     "key-encoded",
+						Value:    "",
+						Usage:    "multibase encoded private key",
+					},
+					&cli.StringFlag{
+						Required: false,
+						Name:     "name-codec",
+						Value:    nameCodecLibp2pKey,
+						Usage:    "how to encode the IPNS names, may be: libp2p-key (a CIDv1 with the libp2p-key codec) or peer-id (the bare peer ID multihash)",
+					},
+					&cli.BoolFlag{
+						Required: false,
+						Name:     "compact",
+						Usage:    "output single line JSON",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					key, err := loadPrivateKey(c.Path("key-file"), c.String("key-encoded"), keyPassword(c))
+					if err != nil {
+						return err
+					}
+
+					return dumpKey(key, c.String("name-codec"), c.Bool("compact"))
+				},
+			},
+			{
+				Name:      "dedupe",
+				Usage:     "dedupe <dir> --out <dir>",
+				UsageText: "keep only the best record for each IPNS name in a directory: valid over invalid, unexpired over expired, then the highest sequence number and latest EOL",
+				Flags: []cli.Flag{
+					&cli.PathFlag{
+						Required: true,
+						Name:     "out",
+						Usage:    "directory to write the kept records to, as <name>.ipns-record",
+					},
+					&cli.StringFlag{
+						Required: false,
+						Name:     "decompress",
+						Value:    "auto",
+						Usage:    "decompression applied to records read from files, may be: none, gzip, or auto (gzip when the file has gzip magic bytes or a .gz extension)",
+					},
+					&cli.BoolFlag{
+						Required: false,
+						Name:     "explain-selection",
+						Usage:    "explain on stderr, step by step, why each record was kept over another: expiry, V2 signature, sequence number, then EOL",
+					},
+					&cli.BoolFlag{
+						Required: false,
+						Name:     "compact",
+						Usage:    "output single line JSON",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return dedupeRecords(c.Args().First(), c.Path("out"), c.String("decompress"), c.Bool("explain-selection"), c.Bool("compact"))
+				},
+			},
+			{
+				Name:      "migrate",
+				Usage:     "migrate --old-dir <dir> --new-key <file> --out <dir>",
+				UsageText: "re-issue the newest record in a directory under a new key, e.g. when rotating away from a compromised key. The value and TTL are carried over and the sequence number starts again from 0",
+				Flags: []cli.Flag{
+					&cli.PathFlag{
+						Required: true,
+						Name:     "old-dir",
+						Usage:    "directory of records for the old name",
+					},
+					&cli.PathFlag{
+						Required: true,
+						Name:     "new-key",
+						Usage:    "the path to the new private key",
+					},
+					&cli.PathFlag{
+						Required: true,
+						Name:     "out",
+						Usage:    "directory to write the new record to, as <new-name>.ipns-record",
+					},
+					&cli.GenericFlag{
+						Required: false,
+						Name:     "lifetime",
+						Value:    &durationValue{d: 24 * time.Hour},
+						Usage:    "how long from now the new record is valid for",
+					},
+					&cli.BoolFlag{
+						Required: false,
+						Name:     "compact",
+						Usage:    "output single line JSON",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					key, err := loadPrivateKey(c.Path("new-key"), "", keyPassword(c))
+					if err != nil {
+						return err
+					}
+
+					return migrateRecords(c.Path("old-dir"), key, c.Path("out"), c.Generic("lifetime").(*durationValue).d, c.Bool("compact"))
+				},
+			},
+			{
+				Name:      "testvectors",
+				Usage:     "testvectors --out <dir>",
+				UsageText: "write a fixed set of keys, records, and their expected parse output to a directory for testing other IPNS implementations",
+				Flags: []cli.Flag{
+					&cli.PathFlag{
+						Required: true,
+						Name:     "out",
+						Usage:    "the directory to write the test vectors to",
+					},
+					&cli.Int64Flag{
+						Required: false,
+						Name:     "seed",
+						Value:    0,
+						Usage:    "the seed used to generate the keys, the same seed always gives the same keys and, except for the ECDSA records whose signatures are randomized, the same records",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return writeTestVectors(c.Path("out"), c.Int64("seed"))
+				},
+			},
+			{
+				Name:      "keyring",
+				Usage:     "keyring <dir>",
+				UsageText: "list the libp2p keys in a directory with their type and IPNS name",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Required: false,
+						Name:     "json",
+						Usage:    "output as JSON instead of a table",
+					},
+					&cli.StringFlag{
+						Required: false,
+						Name:     "sort",
+						Value:    "name",
+						Usage:    "order of the keys, may be: name (IPNS name), type, or file",
+					},
+					&cli.BoolFlag{
+						Required: false,
+						Name:     "compact",
+						Usage:    "with --json, output single line JSON",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return listKeyring(c.Args().First(), c.Bool("json"), c.String("sort"), c.Bool("compact"))
+				},
+			},
+			{
+				Name:      "sign",
+				Usage:     "sign <data>",
+				UsageText: "sign arbitrary data with a libp2p private key using the key type's standard libp2p signature format",
+				Flags: []cli.Flag{
+					&cli.PathFlag{
+						Required: false,
+						Name:     "key-file",
+						Value:    "",
+						Usage:    "The path to the private key",
+					},
+					&cli.PathFlag{
+						Required: false,
+						Name:     "key-encoded",
+						Value:    "",
+						Usage:    "multibase encoded private key",
+					},
+					&cli.StringFlag{
+						Required: false,
+						Name:     "input-type",
+						Value:    "bytes",
+						Usage:    "data input type, may be: auto, bytes, multibase, or path",
+					},
+					&cli.StringFlag{
+						Required: false,
+						Name:     "output-base",
+						Value:    "",
+						Usage:    "multibase name or prefix character, none means no encoding",
+					},
+					&cli.BoolFlag{
+						Required: false,
+						Name:     "recoverable",
+						Usage:    "secp256k1 only. Output a 65 byte R || S || V signature of the sha256 digest of the data, where V is the recovery ID, instead of libp2p's DER signature. The public key can be recovered from this signature",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					data, err := readInput(c.Args().First(), c.String("input-type"), "none", 0)
+					if err != nil {
+						return err
+					}
+
+					key, err := loadPrivateKey(c.Path("key-file"), c.String("key-encoded"), keyPassword(c))
+					if err != nil {
+						return err
+					}
+
+					return signData(key, data, c.Bool("recoverable"), c.String("output-base"))
+				},
+			},
+			{
+				Name:  "convert",
+				Usage: "convert keys and names to other formats",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "name",
+						Usage:     "name --to v1 <name>",
+						UsageText: "re-encode an IPNS name as a CIDv0 or CIDv1, the name may be a CID or a peer ID",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Required: true,
+								Name:     "to",
+								Usage:    "CID version to convert the name to, may be: v0 or v1",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "base",
+								Value:    "",
+								Usage:    "multibase name or prefix character for a v1 name (e.g. base36), defaults to base32. v0 names are always base58btc",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							var version int
+							switch to := c.String("to"); to {
+							case "v0", "0":
+								version = 0
+							case "v1", "1":
+								version = 1
+							default:
+								return fmt.Errorf("cannot convert a name to %q, may be: v0 or v1", to)
+							}
+
+							name, err := convertIPNSName(c.Args().First(), version, c.String("base"))
+							if err != nil {
+								return err
+							}
+							fmt.Println(name)
+							return nil
+						},
+					},
+					{
+						Name:      "key",
+						Usage:     "key --to openssh",
+						UsageText: "convert a libp2p private key to another format. The key goes to stdout unless --out is set, with openssh the public key also goes to stderr or next to --out",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Required: true,
+								Name:     "to",
+								Usage:    "format to convert the key to, may be: openssh, encrypted (with the password from --key-password-env or --key-password), or libp2p (e.g. to decrypt a key)",
+							},
+							&cli.PathFlag{
+								Required: false,
+								Name:     "key-file",
+								Value:    "",
+								Usage:    "The path to the private key",
+							},
+							&cli.PathFlag{
+								Required: false,
+								Name:     "key-encoded",
+								Value:    "",
+								Usage:    "multibase encoded private key",
+							},
+							&cli.PathFlag{
+								Required: false,
+								Name:     "out",
+								Value:    "",
+								Usage:    "path to write the private key to, the public key is written next to it with a .pub extension",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							key, err := loadPrivateKey(c.Path("key-file"), c.String("key-encoded"), keyPassword(c))
+							if err != nil {
+								return err
+							}
+
+							switch to := c.String("to"); to {
+							case "openssh":
+								return convertKeyToOpenSSH(key, c.Path("out"))
+							case "encrypted":
+								return writeKeyFile(key, c.Path("out"), keyPassword(c))
+							case "libp2p":
+								return writeKeyFile(key, c.Path("out"), nil)
+							default:
+								return fmt.Errorf("cannot convert a key to %q, may be: openssh, encrypted, or libp2p", to)
+							}
+						},
+					},
+				},
+			},
+			{
+				Name:      "reconstruct-key",
+				Usage:     "reconstruct-key --share <file> --share <file> ...",
+				UsageText: "rebuild a private key split by create id --shares from at least the threshold of its share files, and check it is the key for the shares' IPNS name",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Required: true,
+						Name:     "share",
+						Usage:    "a share file written by create id --shares, repeat for each share",
+					},
+					&cli.PathFlag{
+						Required: false,
+						Name:     "out",
+						Usage:    "write the key to this file (mode 0600) instead of stdout",
+					},
+					&cli.StringFlag{
+						Required: false,
+						Name:     "output-base",
+						Value:    "",
+						Usage:    "multibase name or prefix character for the key on stdout, none means raw bytes",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					priv, err := reconstructKey(c.StringSlice("share"))
+					if err != nil {
+						return err
+					}
+					if out := c.Path("out"); out != "" {
+						return writeKeyFile(priv, out, nil)
+					}
+					keyBytes, err := crypto.MarshalPrivateKey(priv)
+					if err != nil {
+						return err
+					}
+					return writeOutput(keyBytes, c.String("output-base"))
+				},
+			},
+			{
+				Name:  "publish",
+				Usage: "publish IPNS records",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "gateway",
+						Usage:     "gateway <record>",
+						UsageText: "publish an IPNS record to a gateway using the HTTP routing API (PUT /routing/v1/ipns/{name})",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Required: true,
+								Name:     "gateway",
+								Aliases:  []string{"g"},
+								Usage:    "URL of the gateway, e.g. https://example.com",
+							},
+							&cli.StringFlag{
+								Required: true,
+								Name:     "name",
+								Aliases:  []string{"n"},
+								Usage:    "the IPNS name the record is published under",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "input-type",
+								Value:    "path",
+								Usage:    "record input type, may be: auto, bytes, multibase, or path",
+							},
+							&cli.GenericFlag{
+								Required: false,
+								Name:     "max-size",
+								Value:    &sizeValue{},
+								Usage:    "reject records larger than this size (e.g. 10KiB, the limit in the IPNS spec), 0 means no limit",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "decompress",
+								Value:    "auto",
+								Usage:    "decompression applied to records read from files, may be: none, gzip, or auto (gzip when the file has gzip magic bytes or a .gz extension)",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "dry-run",
+								Usage:    "validate the record against the name and print what would be published without sending anything to the gateway",
+							},
+							&cli.GenericFlag{
+								Required: false,
+								Name:     "repeat",
+								Value:    &durationValue{},
+								Usage:    "keep running and, every interval (e.g. 12h), re-sign the record's value and TTL with the next sequence number and a fresh EOL and publish it again, needs --key-file and --state-file",
+							},
+							&cli.PathFlag{
+								Required: false,
+								Name:     "key-file",
+								Usage:    "the path to the private key used to re-sign the record with --repeat",
+							},
+							&cli.PathFlag{
+								Required: false,
+								Name:     "state-file",
+								Usage:    "file where --repeat keeps the last sequence number, so restarts never reuse one",
+							},
+							&cli.GenericFlag{
+								Required: false,
+								Name:     "lifetime",
+								Value:    &durationValue{d: 48 * time.Hour},
+								Usage:    "how long each record re-signed with --repeat is valid for, must be longer than the interval",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							recordBytes, err := readInput(c.Args().First(), c.String("input-type"), c.String("decompress"), c.Generic("max-size").(*sizeValue).n)
+							if err != nil {
+								return err
+							}
+							if err := checkInputSize(recordBytes, c.Generic("max-size").(*sizeValue)); err != nil {
+								return err
+							}
+
+							if c.IsSet("repeat") {
+								if c.Bool("dry-run") {
+									return errors.New("cannot use --repeat with --dry-run")
+								}
+								if !c.IsSet("key-file") || !c.IsSet("state-file") {
+									return errors.New("--repeat needs --key-file to re-sign the record and --state-file to keep the sequence number")
+								}
+								key, err := loadPrivateKey(c.Path("key-file"), "", keyPassword(c))
+								if err != nil {
+									return err
+								}
+
+								ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+								defer stop()
+								return republish(ctx, c.String("gateway"), c.String("name"), recordBytes, key, c.Path("state-file"), c.Generic("repeat").(*durationValue).d, c.Generic("lifetime").(*durationValue).d)
+							}
+							return publishToGateway(c.Context, c.String("gateway"), c.String("name"), recordBytes, c.Bool("dry-run"))
+						},
+					},
+				},
+			},
+			{
+				Name:  "inspect",
+				Usage: "inspect the internals of IPNS records and names",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "name",
+						Usage:     "name <ipns-name>",
+						UsageText: "output the forms an IPNS name is written in (CIDv1 in base32 and base36, peer ID, and ipns:// URI) and the key type when the name inlines the key, or with --qr show it as a QR code. The name may also be an ipns:// URI",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "uri",
+								Usage:    "only output the canonical ipns:// URI of the name, with the name as a base36 CIDv1",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "qr",
+								Usage:    "render the name as a QR code in the terminal, e.g. for sharing it in a demo",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "qr-content",
+								Value:    "name",
+								Usage:    "what the QR code holds, may be: name (the base36 CIDv1) or uri (the ipns:// URI)",
+							},
+							&cli.PathFlag{
+								Required: false,
+								Name:     "qr-out",
+								Usage:    "also write the QR code to this PNG file",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "compact",
+								Usage:    "output single line JSON",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							name, err := decodeIPNSName(c.Args().First())
+							if err != nil {
+								return err
+							}
+							info, err := inspectName(name)
+							if err != nil {
+								return err
+							}
+
+							qr := c.Bool("qr") || c.IsSet("qr-out")
+							if c.Bool("uri") {
+								if qr {
+									return errors.New("cannot use --uri with --qr, use --qr-content uri for a QR code of the URI")
+								}
+								fmt.Println(info.URI)
+								return nil
+							}
+							if !qr {
+								return printJSON(info, c.Bool("compact"))
+							}
+							switch content := c.String("qr-content"); content {
+							case "name":
+								return printNameQR(info.Base36, c.Path("qr-out"))
+							case "uri":
+								return printNameQR(info.URI, c.Path("qr-out"))
+							default:
+								return fmt.Errorf("unknown --qr-content %q, may be: name or uri", content)
+							}
+						},
+					},
+					{
+						Name:      "signing-bytes",
+						Usage:     "signing-bytes <record>",
+						UsageText: "output the exact bytes the V1 and V2 signatures of an IPNS record are computed over, for checking the signatures with other tools",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Required: false,
+								Name:     "input-type",
+								Value:    "path",
+								Usage:    "record input type, may be: auto, bytes, multibase, or path",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "output-base",
+								Value:    "base16",
+								Usage:    "multibase name or prefix character to encode the signing bytes with",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "compact",
+								Usage:    "output single line JSON",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "decompress",
+								Value:    "auto",
+								Usage:    "decompression applied to records read from files, may be: none, gzip, or auto (gzip when the file has gzip magic bytes or a .gz extension)",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							recordBytes, err := readInput(c.Args().First(), c.String("input-type"), c.String("decompress"), 0)
+							if err != nil {
+								return err
+							}
+							return printSigningBytes(recordBytes, c.String("output-base"), c.Bool("compact"))
+						},
+					},
+					{
+						Name:      "pubkey-from-name",
+						Usage:     "pubkey-from-name <ipns-name>",
+						UsageText: "recover the public key inlined in an IPNS name (e.g. Ed25519 names), names of keys that are too big to inline (e.g. RSA) only have a hash of the key",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Required: false,
+								Name:     "format",
+								Value:    "libp2p",
+								Usage:    "public key format, may be: libp2p, raw, or pem",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "output-base",
+								Value:    "",
+								Usage:    "multibase name or prefix character for the libp2p and raw formats, none means no encoding",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							return pubKeyFromName(c.Args().First(), c.String("format"), c.String("output-base"))
+						},
+					},
+				},
+			},
+			{
+				Name:  "validate",
+				Usage: "validate keys",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "key",
+						Usage:     "key --policy <file>",
+						UsageText: "check a key against a policy of allowed key types and RSA key sizes, failing with the rule that was broken",
+						Flags: []cli.Flag{
+							&cli.PathFlag{
+								Required: false,
+								Name:     "key-file",
+								Value:    "",
+								Usage:    "The path to the private key",
+							},
+							&cli.PathFlag{
+								Required: false,
+								Name:     "key-encoded",
+								Value:    "",
+								Usage:    "multibase encoded private key",
+							},
+							&cli.PathFlag{
+								Required: true,
+								Name:     "policy",
+								Usage:    "YAML or JSON policy file with any of allowed-types (e.g. [ed25519, rsa]), rsa-min-bits, and rsa-max-bits",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							key, err := loadPrivateKey(c.Path("key-file"), c.String("key-encoded"), keyPassword(c))
+							if err != nil {
+								return err
+							}
+
+							policy, err := readKeyPolicy(c.Path("policy"))
+							if err != nil {
+								return err
+							}
+
+							if err := policy.check(key); err != nil {
+								return err
+							}
+							fmt.Println("key satisfies the policy")
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:  "verify",
+				Usage: "verify IPNS records",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "provenance",
+						Usage:     "provenance <document>",
+						UsageText: "verify a provenance document from create provenance: its record is authentic for the name, its fields match the record, and its signature if it is signed",
+						Action: func(c *cli.Context) error {
+							return verifyProvenance(c.Args().First())
+						},
+					},
+					{
+						Name:      "ownership",
+						Usage:     "ownership --key-file <path> <record>",
+						UsageText: "check a private key owns an IPNS record, i.e. the record was signed by the key and is for the key's name, before re-signing or republishing it",
+						Flags: []cli.Flag{
+							&cli.PathFlag{
+								Required: true,
+								Name:     "key-file",
+								Usage:    "the path to the private key",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "decompress",
+								Value:    "auto",
+								Usage:    "decompression applied to records read from files, may be: none, gzip, or auto (gzip when the file has gzip magic bytes or a .gz extension)",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							key, err := loadPrivateKey(c.Path("key-file"), "", keyPassword(c))
+							if err != nil {
+								return err
+							}
+							return verifyOwnership(key, c.Args().First(), c.String("decompress"))
+						},
+					},
+					{
+						Name:      "record",
+						Usage:     "record <record>",
+						UsageText: "verify an IPNS record is validly signed by the key for the IPNS name and has not expired",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Required: true,
+								Name:     "name",
+								Aliases:  []string{"n"},
+								Usage:    "the IPNS name the record is stored under",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "input-type",
+								Value:    "path",
+								Usage:    "record input type, may be: auto, bytes, multibase, or path",
+							},
+							&cli.GenericFlag{
+								Required: false,
+								Name:     "max-size",
+								Value:    &sizeValue{},
+								Usage:    "reject records larger than this size (e.g. 10KiB, the limit in the IPNS spec), 0 means no limit",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "decompress",
+								Value:    "auto",
+								Usage:    "decompression applied to records read from files, may be: none, gzip, or auto (gzip when the file has gzip magic bytes or a .gz extension)",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "accept-expired",
+								Usage:    "only check the record is authentic, an expired record is reported but not treated as a failure",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "gateway-policy",
+								Usage:    "also report how CDN-style gateways will cache the record, warning when the TTL is missing, 0 (uncacheable), or over --gateway-max-ttl",
+							},
+							&cli.GenericFlag{
+								Required: false,
+								Name:     "gateway-max-ttl",
+								Value:    &durationValue{d: defaultGatewayMaxTTL},
+								Usage:    "the longest TTL gateways are assumed to honour with --gateway-policy, longer TTLs are clamped to it",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "domain-separation",
+								Usage:    "first check the V2 signature is over \"ipns-signature:\" + the CBOR data, and report which wrong or legacy prefix it was made with if not",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							if c.IsSet("gateway-max-ttl") && !c.Bool("gateway-policy") {
+								return errors.New("--gateway-max-ttl is only used with --gateway-policy")
+							}
+							recordBytes, err := readInput(c.Args().First(), c.String("input-type"), c.String("decompress"), c.Generic("max-size").(*sizeValue).n)
+							if err != nil {
+								return err
+							}
+							if err := checkInputSize(recordBytes, c.Generic("max-size").(*sizeValue)); err != nil {
+								return err
+							}
+
+							name, err := decodeIPNSName(c.String("name"))
+							if err != nil {
+								return err
+							}
+
+							rec, err := unmarshalIPNSRecord(recordBytes)
+							if err != nil {
+								return err
+							}
+
+							if c.Bool("domain-separation") {
+								if err := checkDomainSeparation(name, rec); err != nil {
+									return err
+								}
+								fmt.Printf("the V2 signature uses the %q domain separation prefix\n", ipnsSignatureV2Prefix)
+							}
+
+							expired, err := verifyIPNSRecord(name, rec, c.Bool("accept-expired"))
+							if err != nil {
+								return err
+							}
+							if expired {
+								fmt.Println("record signature is valid, but the record has expired (ignored with --accept-expired)")
+							} else {
+								fmt.Println("record is valid")
+							}
+
+							if c.Bool("gateway-policy") {
+								caching, warnings, err := checkGatewayPolicy(rec, c.Generic("gateway-max-ttl").(*durationValue).d, time.Now())
+								if err != nil {
+									return err
+								}
+								for _, w := range warnings {
+									fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+								}
+								fmt.Println(caching)
+							}
+							return nil
+						},
+					},
+					{
+						Name:      "dnslink",
+						Usage:     "dnslink --domain <domain> <record>",
+						UsageText: "verify an IPNS record against the IPNS name the domain's DNSLink points at",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Required: true,
+								Name:     "domain",
+								Aliases:  []string{"d"},
+								Usage:    "the domain whose _dnslink TXT record points at the IPNS name, e.g. example.com",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "input-type",
+								Value:    "path",
+								Usage:    "record input type, may be: auto, bytes, multibase, or path",
+							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "decompress",
+								Value:    "auto",
+								Usage:    "decompression applied to records read from files, may be: none, gzip, or auto (gzip when the file has gzip magic bytes or a .gz extension)",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "accept-expired",
+								Usage:    "only check the record is authentic, an expired record is reported but not treated as a failure",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							recordBytes, err := readInput(c.Args().First(), c.String("input-type"), c.String("decompress"), 0)
+							if err != nil {
+								return err
+							}
+
+							name, err := resolveDNSLinkName(c.Context, c.String("domain"))
+							if err != nil {
+								return err
+							}
+
+							rec, err := unmarshalIPNSRecord(recordBytes)
+							if err != nil {
+								return err
+							}
+
+							expired, err := verifyIPNSRecord(name, rec, c.Bool("accept-expired"))
+							if err != nil {
+								return fmt.Errorf("record does not match %s (%s): %w", c.String("domain"), peer.ToCid(name), err)
+							}
+							if expired {
+								fmt.Printf("record signature is valid for %s (%s), but the record has expired (ignored with --accept-expired)\n", c.String("domain"), peer.ToCid(name))
+								return nil
+							}
+							fmt.Printf("record is valid for %s (%s)\n", c.String("domain"), peer.ToCid(name))
+							return nil
+						},
+					},
 					{
-						Name:  "id",
-						Usage: "create an IPNS identifier",
+						Name:      "records",
+						Usage:     "records <dir>",
+						UsageText: "verify every IPNS record in a directory. Records are verified against the IPNS name in their file name (e.g. <name>.ipns-record), or their embedded public key if the file name is not an IPNS name",
 						Flags: []cli.Flag{
-							&cli.StringFlag{
+							&cli.BoolFlag{
 								Required: false,
-								Name:     "output-base",
-								Value:    "",
-								Usage:    "multibase name or prefix character, none means no encoding",
+								Name:     "fail-fast",
+								Usage:    "stop at the first record that fails",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "continue-on-error",
+								Value:    true,
+								Usage:    "process every record and report a summary of the failures at the end",
 							},
 							&cli.StringFlag{
 								Required: false,
-								Name:     "type",
-								Value:    "ed25519",
-								Usage:    "type of the key to create",
+								Name:     "decompress",
+								Value:    "auto",
+								Usage:    "decompression applied to records read from files, may be: none, gzip, or auto (gzip when the file has gzip magic bytes or a .gz extension)",
 							},
-							&cli.IntFlag{
+							&cli.BoolFlag{
 								Required: false,
-								Name:     "size",
-								Value:    -1,
-								Usage:    "size of the key to generate (only valid to be set for RSA keys which defaults to 2048)",
+								Name:     "accept-expired",
+								Usage:    "only check the record is authentic, an expired record is reported but not treated as a failure",
 							},
 						},
 						Action: func(c *cli.Context) error {
-							return createIPNSID(c.String("type"), c.Int("size"), c.String("output-base"))
+							failFast, err := failFastMode(c)
+							if err != nil {
+								return err
+							}
+
+							decompress, acceptExpired := c.String("decompress"), c.Bool("accept-expired")
+							return processDirectory(c.Args().First(), failFast, func(path string) error {
+								return verifyIPNSRecordFile(path, decompress, acceptExpired)
+							})
 						},
 					},
 					{
-						Name:      "record",
-						Usage:     "record <value>",
-						UsageText: "create an IPNS record",
+						Name:      "batch",
+						Usage:     "batch --csv <file>",
+						UsageText: "verify the records listed in a CSV file against their IPNS names and output whether each passed",
 						Flags: []cli.Flag{
 							&cli.PathFlag{
-								Required: false,
-								Name:     "key-file",
-								Value:    "",
-								Usage:    "The path to the private key",
+								Required: true,
+								Name:     "csv",
+								Usage:    "CSV file with rows of an IPNS name and the path to its record file, relative paths are relative to the CSV file",
 							},
-							&cli.PathFlag{
+							&cli.StringFlag{
 								Required: false,
-								Name:     "key-encoded",
-								Value:    "",
-								Usage:    "multibase encoded private key",
+								Name:     "format",
+								Value:    "csv",
+								Usage:    "output format, may be: csv or json",
 							},
 							&cli.StringFlag{
 								Required: false,
-								Name:     "output-base",
-								Value:    "",
-								Usage:    "multibase name or prefix character, none means no encoding",
+								Name:     "decompress",
+								Value:    "auto",
+								Usage:    "decompression applied to records read from files, may be: none, gzip, or auto (gzip when the file has gzip magic bytes or a .gz extension)",
 							},
-							&cli.DurationFlag{
+							&cli.BoolFlag{
 								Required: false,
-								Name:     "ttl",
-								Value:    0,
-							},
-							&cli.TimestampFlag{
-								Required:    false,
-								Name:        "eol",
-								Layout:      "2006-01-02T15:04:05",
-								DefaultText: "End of life for the record, in UTC. Time format is 2006-01-02T15:04:05. Defaults to 24 hours from now",
-							},
-							&cli.DurationFlag{
-								Required:    false,
-								Name:        "lifetime",
-								DefaultText: "An alternative to eol. Defines how long from now a record should be valid for (e.g. 30s, -10m, 24.5h). Defaults to 24 hours",
+								Name:     "accept-expired",
+								Usage:    "only check the record is authentic, an expired record is reported but not treated as a failure",
 							},
-							&cli.Int64Flag{
+							&cli.PathFlag{
 								Required: false,
-								Name:     "seqno",
-								Value:    0,
+								Name:     "names-file",
+								Usage:    "only check records for the IPNS names in this file, one per line, the rest are skipped and counted",
 							},
-							&cli.StringFlag{
+							&cli.BoolFlag{
 								Required: false,
-								Name:     "value",
-								Value:    "/ipfs/bafkqaaa",
+								Name:     "compact",
+								Usage:    "with --format json, output single line JSON",
 							},
 						},
 						Action: func(c *cli.Context) error {
-							seqno := c.Int64("seqno")
-							ttl := c.Duration("ttl")
-							eol := c.Timestamp("eol")
-							const lifetimeStr = "lifetime"
-							lifetime := c.Duration(lifetimeStr)
-
-							if c.IsSet(lifetimeStr) && eol != nil {
-								return errors.New("cannot define lifetime and eol on a record, choose one")
-							}
-
-							if !c.IsSet(lifetimeStr) && eol == nil {
-								eolTime := time.Now().Add(time.Hour * 24)
-								eol = &eolTime
-							} else if c.IsSet(lifetimeStr) {
-								eolTime := time.Now().Add(lifetime)
-								eol = &eolTime
+							allow, err := readNamesFile(c.Path("names-file"))
+							if err != nil {
+								return err
 							}
+							return verifyBatch(c.Path("csv"), c.String("format"), c.String("decompress"), c.Bool("accept-expired"), allow, c.Bool("compact"))
+						},
+					},
+				},
+			},
+			{
+				Name:      "resolve",
+				Usage:     "resolve <ipns-name>",
+				UsageText: "fetch the record for an IPNS name from a gateway, verify it, and output the content path it points to",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Required: true,
+						Name:     "gateway",
+						Usage:    "URL of a gateway implementing the routing API (e.g. https://delegated-ipfs.dev), may be repeated with --exit-on-first-valid",
+					},
+					&cli.BoolFlag{
+						Required: false,
+						Name:     "exit-on-first-valid",
+						Usage:    "query every --gateway concurrently and use the first valid, unexpired record, reporting which gateway answered",
+					},
+					&cli.BoolFlag{
+						Required: false,
+						Name:     "recursive",
+						Aliases:  []string{"r"},
+						Usage:    "follow values that point at other IPNS names until reaching an /ipfs/ path",
+					},
+					&cli.IntFlag{
+						Required: false,
+						Name:     "max-depth",
+						Value:    32,
+						Usage:    "maximum number of records to follow with --recursive",
+					},
+					&cli.GenericFlag{
+						Required: false,
+						Name:     "timeout",
+						Value:    &durationValue{d: time.Minute},
+						Usage:    "how long to wait for the whole resolution",
+					},
+					&cli.BoolFlag{
+						Required: false,
+						Name:     "value-only",
+						Aliases:  []string{"name-only"},
+						Usage:    "output only the resolved path, e.g. for $(...) capture, diagnostics still go to stderr",
+					},
+					&cli.PathFlag{
+						Required: false,
+						Name:     "record-out",
+						Usage:    "also write the raw record fetched for the name to this file (mode 0600), for parsing or verifying it offline later",
+					},
+					&cli.BoolFlag{
+						Required: false,
+						Name:     "compact",
+						Usage:    "output single line JSON",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					name, err := decodeIPNSName(c.Args().First())
+					if err != nil {
+						return err
+					}
 
-							value := c.String("value")
-							keyFile := c.Path("key-file")
-							keyEncoded := c.String("key-encoded")
-
-							var key crypto.PrivKey
-							if keyFile != "" && keyEncoded != "" {
-								return errors.New("cannot pass a key file and encoded key")
-							} else if keyFile == "" && keyEncoded == "" {
-								return errors.New("no key specified, specify a key file or encoded key")
-							} else if keyFile != "" {
-								keyBytes, err := os.ReadFile(keyFile)
-								if err != nil {
-									return err
-								}
-								priv, err := crypto.UnmarshalPrivateKey(keyBytes)
-								if err != nil {
-									return err
-								}
-								key = priv
-							} else {
-								_, keyBytes, err := multibase.Decode(keyEncoded)
-								if err != nil {
-									return err
-								}
-								priv, err := crypto.UnmarshalPrivateKey(keyBytes)
-								if err != nil {
-									return err
-								}
-								key = priv
+					ctx, cancel := context.WithTimeout(c.Context, c.Generic("timeout").(*durationValue).d)
+					defer cancel()
+					gateways := c.StringSlice("gateway")
+					if len(gateways) > 1 && !c.Bool("exit-on-first-valid") {
+						return fmt.Errorf("%d gateways were passed, pass --exit-on-first-valid to query them all and use the first valid answer", len(gateways))
+					}
+					if maxDepth := c.Int("max-depth"); maxDepth < 1 {
+						return fmt.Errorf("--max-depth must be at least 1, got %d", maxDepth)
+					}
+					res, err := resolveName(ctx, gateways, name, c.Bool("recursive"), c.Int("max-depth"))
+					if out := c.Path("record-out"); out != "" && res != nil && res.record != nil {
+						if writeErr := os.WriteFile(out, res.record, 0600); writeErr != nil {
+							return fmt.Errorf("could not write the record to %s: %w", out, writeErr)
+						}
+						log.Infow("wrote the fetched record", "name", peer.ToCid(name), "path", out)
+					}
+					if c.Bool("value-only") {
+						if err != nil {
+							return err
+						}
+						_, err := fmt.Println(res.Path)
+						return err
+					}
+					if err != nil {
+						if len(res.Chain) > 0 {
+							if printErr := printJSON(res, c.Bool("compact")); printErr != nil {
+								return printErr
 							}
-
-							return createIPNSRecord(seqno, ttl, *eol, value, key, c.String("output-base"))
+						}
+						return err
+					}
+					return printJSON(res, c.Bool("compact"))
+				},
+			},
+			{
+				Name:      "drift",
+				Usage:     "drift --expected-dir <dir> --gateway <url>",
+				UsageText: "compare a directory of expected records with the records the network serves for their names and report what drifted",
+				Flags: []cli.Flag{
+					&cli.PathFlag{
+						Required: true,
+						Name:     "expected-dir",
+						Usage:    "directory of expected records, named by IPNS name or with embedded public keys",
+					},
+					&cli.StringFlag{
+						Required: true,
+						Name:     "gateway",
+						Usage:    "URL of a gateway implementing the routing API (e.g. https://delegated-ipfs.dev)",
+					},
+					&cli.StringFlag{
+						Required: false,
+						Name:     "decompress",
+						Value:    "auto",
+						Usage:    "decompression applied to records read from files, may be: none, gzip, or auto (gzip when the file has gzip magic bytes or a .gz extension)",
+					},
+					&cli.GenericFlag{
+						Required: false,
+						Name:     "timeout",
+						Value:    &durationValue{d: 30 * time.Second},
+						Usage:    "how long to wait for the network record of each name",
+					},
+					&cli.IntFlag{
+						Required: false,
+						Name:     "concurrency",
+						Aliases:  []string{"max-concurrency"},
+						Value:    1,
+						Usage:    "how many names to check at once",
+					},
+					&cli.GenericFlag{
+						Required: false,
+						Name:     "rate-limit",
+						Value:    &rateValue{},
+						Usage:    "start at most this many requests to the gateway, e.g. 10/s or 600/m, 0 means no limit. 429 responses always pause requests and are retried",
+					},
+					&cli.BoolFlag{
+						Required: false,
+						Name:     "compact",
+						Usage:    "output single line JSON",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					limiter, err := newRequestLimiter(c.Generic("rate-limit").(*rateValue).perSecond)
+					if err != nil {
+						return err
+					}
+					return detectDrift(c.Context, c.Path("expected-dir"), c.String("gateway"), c.String("decompress"), c.Generic("timeout").(*durationValue).d, c.Int("concurrency"), limiter, c.Bool("compact"))
+				},
+			},
+			{
+				Name:      "audit",
+				Usage:     "audit --names-file <file> --gateway <url>",
+				UsageText: "fetch the record for every name in a file from the network through a gateway, verify it, and report each name's value, sequence number, EOL, and whether it is healthy",
+				Flags: []cli.Flag{
+					&cli.PathFlag{
+						Required: true,
+						Name:     "names-file",
+						Usage:    "file with one IPNS name per line, blank lines and lines starting with # are ignored",
+					},
+					&cli.StringFlag{
+						Required: true,
+						Name:     "gateway",
+						Usage:    "URL of a gateway implementing the routing API (e.g. https://delegated-ipfs.dev)",
+					},
+					&cli.IntFlag{
+						Required: false,
+						Name:     "concurrency",
+						Aliases:  []string{"max-concurrency"},
+						Value:    8,
+						Usage:    "how many names to fetch at once",
+					},
+					&cli.GenericFlag{
+						Required: false,
+						Name:     "rate-limit",
+						Value:    &rateValue{},
+						Usage:    "start at most this many requests to the gateway, e.g. 10/s or 600/m, 0 means no limit. 429 responses always pause requests and are retried",
+					},
+					&cli.GenericFlag{
+						Required: false,
+						Name:     "timeout",
+						Value:    &durationValue{d: 30 * time.Second},
+						Usage:    "how long to wait for the record of each name",
+					},
+					&cli.StringFlag{
+						Required: false,
+						Name:     "format",
+						Value:    "json",
+						Usage:    "report format, may be: json or csv (the summary is written to stderr)",
+					},
+					&cli.BoolFlag{
+						Required: false,
+						Name:     "compact",
+						Usage:    "output single line JSON",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					names, err := readNamesList(c.Path("names-file"))
+					if err != nil {
+						return err
+					}
+					limiter, err := newRequestLimiter(c.Generic("rate-limit").(*rateValue).perSecond)
+					if err != nil {
+						return err
+					}
+					return auditNames(c.Context, names, c.String("gateway"), c.Generic("timeout").(*durationValue).d, c.Int("concurrency"), limiter, c.String("format"), c.Bool("compact"))
+				},
+			},
+			{
+				Name:   "selftest",
+				Usage:  "check the libraries ipns-utils depends on behave as expected",
+				Hidden: true,
+				Subcommands: []*cli.Command{
+					{
+						Name:      "multibase",
+						Usage:     "multibase",
+						UsageText: "round trip random data, a record, and a key through every multibase the way --output-base encodes them, failing if any come back different",
+						Action: func(c *cli.Context) error {
+							return selftestMultibase()
 						},
 					},
 				},
 			},
 			{
-				Name: "parse",
+				Name:  "schema",
+				Usage: "print the JSON Schema of a command's JSON output",
 				Subcommands: []*cli.Command{
 					{
-						Name:      "record",
-						Usage:     "record <record>",
-						UsageText: "parse an IPNS record. The public key, if present, is multibase encoded",
+						Name:  "parse-record",
+						Usage: "schema of the parse record output",
 						Flags: []cli.Flag{
-							&cli.StringFlag{
+							&cli.BoolFlag{
 								Required: false,
-								Name:     "input-type",
-								Value:    "bytes",
-								Usage:    "record input type, may be: bytes, multibase, or path",
+								Name:     "compact",
+								Usage:    "output single line JSON",
 							},
 						},
 						Action: func(c *cli.Context) error {
-							recordInput := c.Args().First()
-							inputType := c.Path("input-type")
-							var recordBytes []byte
-							var err error
-							switch inputType {
-							case "bytes":
-								recordBytes = []byte(recordInput)
-							case "multibase":
-								_, recordBytes, err = multibase.Decode(recordInput)
-								if err != nil {
-									return err
-								}
-							case "path":
-								recordBytes, err = os.ReadFile(recordInput)
-								if err != nil {
-									return err
-								}
-							default:
-								return errors.New("must pass either a record file or encoded record to parse")
-							}
-
-							return parseIPNSRecord(recordBytes)
+							return printSchema("parse-record", c.Bool("compact"))
 						},
 					},
 					{
-						Name:      "key",
-						Usage:     "key <key>",
-						UsageText: "parse the encoded libp2p key format used with IPNS. The key material is multibase encoded",
+						Name:  "parse-records",
+						Usage: "schema of the parse records output",
 						Flags: []cli.Flag{
-							&cli.StringFlag{
+							&cli.BoolFlag{
 								Required: false,
-								Name:     "input-type",
-								Value:    "bytes",
-								Usage:    "record input type, may be: bytes, multibase, or path",
+								Name:     "compact",
+								Usage:    "output single line JSON",
 							},
+						},
+						Action: func(c *cli.Context) error {
+							return printSchema("parse-records", c.Bool("compact"))
+						},
+					},
+					{
+						Name:  "parse-key",
+						Usage: "schema of the parse key output",
+						Flags: []cli.Flag{
 							&cli.BoolFlag{
 								Required: false,
-								Name:     "private-key",
-								Value:    true,
+								Name:     "compact",
+								Usage:    "output single line JSON",
 							},
 						},
 						Action: func(c *cli.Context) error {
-							keyInput := c.Args().First()
-							inputType := c.Path("input-type")
-							var keyBytes []byte
-							var err error
-							switch inputType {
-							case "bytes":
-								keyBytes = []byte(keyInput)
-							case "multibase":
-								_, keyBytes, err = multibase.Decode(keyInput)
-								if err != nil {
-									return err
-								}
-							case "path":
-								keyBytes, err = os.ReadFile(keyInput)
-								if err != nil {
-									return err
-								}
-							default:
-								return errors.New("must pass either a record file or encoded record to parse")
-							}
-
-							return parselibp2pkey(keyBytes, c.Bool("private-key"))
+							return printSchema("parse-key", c.Bool("compact"))
 						},
 					},
 				},
@@ -257,27 +2350,86 @@ func main() {
 				Usage:   "IPNS over PubSub utilities",
 				Subcommands: []*cli.Command{
 					{
-						Name:    "get-topic",
-						Aliases: []string{"t"},
-						Usage:   "get pubsub topic name from key",
+						Name:      "get-topic",
+						Aliases:   []string{"t"},
+						Usage:     "get pubsub topic name from key",
+						ArgsUsage: "[key]",
 						Flags: []cli.Flag{
 							&cli.StringFlag{
-								Required:    true,
+								Required:    false,
 								Name:        "key",
 								Aliases:     []string{"k"},
-								Usage:       "The CIDv0 or CIDv1 representations of an IPNS Key",
+								Usage:       "The CIDv0, CIDv1, or peer ID representations of an IPNS Key, may also be passed as an argument",
 								Destination: &ipnsKey,
 							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "output-base",
+								Value:    "",
+								Usage:    "multibase name or prefix character to encode the topic with, by default it is output as is",
+							},
 						},
 						Action: func(c *cli.Context) error {
+							if ipnsKey == "" {
+								ipnsKey = c.Args().First()
+							}
+							if ipnsKey == "" {
+								return errors.New("no IPNS key specified, pass it with --key or as an argument")
+							}
 							topic, err := getPubSubTopic(ipnsKey)
 							if err != nil {
 								return err
 							}
+							if base := c.String("output-base"); base != "" {
+								enc, err := multibase.EncoderByName(base)
+								if err != nil {
+									return err
+								}
+								topic = enc.Encode([]byte(topic))
+							}
 							fmt.Println(topic)
 							return nil
 						},
 					},
+					{
+						Name:      "watch",
+						Usage:     "watch [file]",
+						UsageText: "validate the IPNS records in the output of `ipfs pubsub sub --enc=json <topic>`, read from stdin or a file. Each message that doesn't hold a valid record is output as a single line of JSON with the name, reason, seqno, and sending peer",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Required: false,
+								Name:     "name",
+								Aliases:  []string{"n"},
+								Usage:    "the IPNS name to validate records against, by default it comes from each message's topic",
+							},
+							&cli.PathFlag{
+								Required: false,
+								Name:     "names-file",
+								Usage:    "only check records for the IPNS names in this file, one per line, the rest are skipped and counted",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "compact",
+								Usage:    "with --format json, output single line JSON",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							in := os.Stdin
+							if path := c.Args().First(); path != "" && path != "-" {
+								f, err := os.Open(path)
+								if err != nil {
+									return err
+								}
+								defer f.Close()
+								in = f
+							}
+							allow, err := readNamesFile(c.Path("names-file"))
+							if err != nil {
+								return err
+							}
+							return watchPubSub(in, os.Stdout, c.String("name"), allow)
+						},
+					},
 					{
 						Name:    "get-key",
 						Usage:   "get IPNS key from pubsub topic",
@@ -320,30 +2472,77 @@ func main() {
 								Usage:       "The CIDv0 or CIDv1 representations of an IPNS Key",
 								Destination: &topic,
 							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "output-base",
+								Value:    "",
+								Usage:    "multibase name or prefix character to encode the DHT key CID with, by default it is output as is",
+							},
 						},
 						Action: func(c *cli.Context) error {
 							key, err := getDHTRendezvousKey(topic)
 							if err != nil {
 								return err
 							}
+							if key, err = encodeCIDString(key, c.String("output-base")); err != nil {
+								return err
+							}
 							fmt.Println(key)
 							return nil
 						},
 					},
 					{
-						Name:    "get-dht-key-from-key",
-						Usage:   "get the rendezvous DHT key from the IPNS key",
-						Aliases: []string{"dkk"},
+						Name:      "rendezvous-variants",
+						Usage:     "rendezvous-variants --topic <topic>",
+						UsageText: "output the DHT rendezvous key of a pubsub topic under every known convention for deriving it, to find out why peers on different versions don't find each other",
 						Flags: []cli.Flag{
 							&cli.StringFlag{
-								Required:    true,
+								Required: true,
+								Name:     "topic",
+								Aliases:  []string{"t"},
+								Usage:    "the pubsub topic, e.g. /record/L2lwbnMv...",
+							},
+							&cli.BoolFlag{
+								Required: false,
+								Name:     "compact",
+								Usage:    "output single line JSON",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							variants, err := getDHTRendezvousVariants(c.String("topic"))
+							if err != nil {
+								return err
+							}
+							return printJSON(variants, c.Bool("compact"))
+						},
+					},
+					{
+						Name:      "get-dht-key-from-key",
+						Usage:     "get the rendezvous DHT key from the IPNS key",
+						ArgsUsage: "[key]",
+						Aliases:   []string{"dkk"},
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Required:    false,
 								Name:        "key",
 								Aliases:     []string{"k"},
-								Usage:       "The CIDv0 or CIDv1 representations of an IPNS Key",
+								Usage:       "The CIDv0, CIDv1, or peer ID representations of an IPNS Key, may also be passed as an argument",
 								Destination: &ipnsKey,
 							},
+							&cli.StringFlag{
+								Required: false,
+								Name:     "output-base",
+								Value:    "",
+								Usage:    "multibase name or prefix character to encode the DHT key CID with, by default it is output as is",
+							},
 						},
 						Action: func(c *cli.Context) error {
+							if ipnsKey == "" {
+								ipnsKey = c.Args().First()
+							}
+							if ipnsKey == "" {
+								return errors.New("no IPNS key specified, pass it with --key or as an argument")
+							}
 							topic, err := getPubSubTopic(ipnsKey)
 							if err != nil {
 								return err
@@ -352,6 +2551,9 @@ func main() {
 							if err != nil {
 								return err
 							}
+							if key, err = encodeCIDString(key, c.String("output-base")); err != nil {
+								return err
+							}
 							fmt.Println(key)
 							return nil
 						},
@@ -360,6 +2562,9 @@ func main() {
 			},
 		},
 	}
+	addBaseShortcutFlags(app.Commands)
+	addKeyPasswordFlags(app.Commands)
+	setConfigDefaults(app)
 
 	err := app.Run(os.Args)
 	if err != nil {
@@ -367,10 +2572,8 @@ func main() {
 	}
 }
 
-func createIPNSID(keyType string, keyLen int, outputBase string) error {
-	var priv crypto.PrivKey
-	var pub crypto.PubKey
-
+// generateKey creates a new private key of the given type, keyLen is only used for RSA keys
+func generateKey(keyType string, keyLen int) (crypto.PrivKey, error) {
 	switch keyType {
 	case "rsa":
 		rsaLen := keyLen
@@ -378,32 +2581,27 @@ func createIPNSID(keyType string, keyLen int, outputBase string) error {
 			rsaLen = 2048
 		}
 
-		var err error
-		priv, pub, err = crypto.GenerateKeyPairWithReader(crypto.RSA, rsaLen, rand.Reader)
-		if err != nil {
-			return err
-		}
+		priv, _, err := crypto.GenerateKeyPairWithReader(crypto.RSA, rsaLen, rand.Reader)
+		return priv, err
 	case "ed25519":
-		var err error
-		priv, pub, err = crypto.GenerateEd25519Key(rand.Reader)
-		if err != nil {
-			return err
-		}
+		priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+		return priv, err
 	case "secp256k1":
-		var err error
-		priv, pub, err = crypto.GenerateSecp256k1Key(rand.Reader)
-		if err != nil {
-			return err
-		}
+		priv, _, err := crypto.GenerateSecp256k1Key(rand.Reader)
+		return priv, err
 	case "ecdsa":
-		var err error
-		priv, pub, err = crypto.GenerateECDSAKeyPair(rand.Reader)
-		if err != nil {
-			return err
-		}
+		priv, _, err := crypto.GenerateECDSAKeyPair(rand.Reader)
+		return priv, err
 	default:
-		return crypto.ErrBadKeyType
+		return nil, crypto.ErrBadKeyType
 	}
+}
+
+// createIPNSID writes the private key and the IPNS name of the key to the streams chosen by stdout and stderr.
+// Each may be key, name, or none (stderr only). The key is encoded with outputBase, the name is encoded with nameBase
+// and written as a line, with an "identifier: " label on stderr unless bareName is set.
+func createIPNSID(priv crypto.PrivKey, outputBase, nameBase, stdout, stderr string, bareName bool) error {
+	pub := priv.GetPublic()
 
 	privKeyBytes, err := crypto.MarshalPrivateKey(priv)
 	if err != nil {
@@ -414,96 +2612,549 @@ func createIPNSID(keyType string, keyLen int, outputBase string) error {
 	if err != nil {
 		return err
 	}
-
-	if _, err := fmt.Fprintf(os.Stderr, "identfier: %s\n", peer.ToCid(recPkHash)); err != nil {
+	name, err := encodeCIDString(peer.ToCid(recPkHash).String(), nameBase)
+	if err != nil {
 		return err
 	}
 
-	if outputBase != "" {
-		enc, err := multibase.EncoderByName(outputBase)
-		if err != nil {
+	switch stderr {
+	case "name":
+		label := "identifier: "
+		if bareName {
+			label = ""
+		}
+		if _, err := fmt.Fprintf(os.Stderr, "%s%s\n", label, name); err != nil {
 			return err
 		}
-		fmt.Printf(enc.Encode(privKeyBytes))
-		return nil
+	case "key":
+		if err := writeEncoded(os.Stderr, privKeyBytes, outputBase); err != nil {
+			return err
+		}
+	case "none":
+	default:
+		return fmt.Errorf("unknown --stderr %q, may be: name, key, or none", stderr)
+	}
+
+	switch stdout {
+	case "key":
+		return writeOutput(privKeyBytes, outputBase)
+	case "name":
+		_, err := fmt.Println(name)
+		return err
+	default:
+		return fmt.Errorf("unknown --stdout %q, may be: key or name", stdout)
 	}
-	_, err = os.Stdout.Write(privKeyBytes)
-	return nil
 }
 
-func createIPNSRecord(seqno int64, ttl time.Duration, eol time.Time, value string, privKey crypto.PrivKey, outputBase string) error {
-	rec, err := ipns.Create(privKey, []byte(value), uint64(seqno), eol, ttl)
+// createSharedID is create id --shares, the new key is only written as shares and its name goes to stdout
+func createSharedID(c *cli.Context) error {
+	if !c.IsSet("shares") || !c.IsSet("threshold") {
+		return errors.New("--shares and --threshold must be used together")
+	}
+	for _, f := range []string{"stdout", "stderr", "output-base", "kubo-import"} {
+		if c.IsSet(f) {
+			return fmt.Errorf("cannot use --%s with --shares, the key is only written as shares", f)
+		}
+	}
+
+	priv, err := generateKey(c.String("type"), c.Int("size"))
+	if err != nil {
+		return err
+	}
+	paths, err := writeKeyShares(priv, c.Int("shares"), c.Int("threshold"), c.Path("share-dir"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		fmt.Fprintf(os.Stderr, "wrote share %s\n", path)
+	}
+	return createIPNSID(priv, "", c.String("name-base"), "name", "none", c.Bool("bare-name"))
+}
+
+// checkKeyName confirms the private key is the key for the IPNS name and shows the name on stderr
+func checkKeyName(name string, priv crypto.PrivKey) error {
+	expected, err := decodeIPNSName(name)
+	if err != nil {
+		return err
+	}
+	actual, err := peer.IDFromPrivateKey(priv)
 	if err != nil {
 		return err
 	}
+	if actual != expected {
+		return fmt.Errorf("the key is for IPNS name %s, not %s", peer.ToCid(actual), name)
+	}
+
+	fmt.Fprintf(os.Stderr, "IPNS name: %s\n", name)
+	return nil
+}
+
+// loadPKCS11KeyFlags loads the PKCS#11 key chosen by the --pkcs11-* flags, asking for the PIN if it isn't in the environment
+func loadPKCS11KeyFlags(c *cli.Context, module string) (crypto.PrivKey, error) {
+	if !pkcs11Supported {
+		return loadPKCS11Key(module, "", "")
+	}
+	label := c.String("pkcs11-label")
+	if label == "" {
+		return nil, errors.New("using a PKCS#11 key requires its label, pass it with --pkcs11-label")
+	}
+
+	pin, ok := os.LookupEnv(c.String("pkcs11-pin-env"))
+	if !ok {
+		var err error
+		if pin, err = newPrompter().promptHidden("PKCS#11 PIN"); err != nil {
+			return nil, err
+		}
+	}
+	return loadPKCS11Key(module, label, pin)
+}
+
+// encodedKey returns the multibase encoded private key from --key-encoded or the environment variable named by --key-env
+func encodedKey(c *cli.Context) (string, error) {
+	name := c.String("key-env")
+	if name == "" {
+		return c.String("key-encoded"), nil
+	}
+	if c.IsSet("key-encoded") {
+		return "", errors.New("cannot use --key-encoded and --key-env together, choose one")
+	}
+
+	key, ok := os.LookupEnv(name)
+	if !ok || key == "" {
+		return "", fmt.Errorf("environment variable %s passed with --key-env is not set", name)
+	}
+	log.Debugw("read encoded key from the environment", "variable", name)
+	return key, nil
+}
+
+// loadPrivateKey reads a private key from either a key file or a multibase encoded string
+func loadPrivateKey(keyFile, keyEncoded string, password func() (string, error)) (crypto.PrivKey, error) {
+	var keyBytes []byte
+	if keyFile != "" && keyEncoded != "" {
+		return nil, errors.New("cannot pass a key file and encoded key")
+	} else if keyFile == "" && keyEncoded == "" {
+		return nil, errors.New("no key specified, specify a key file or encoded key")
+	} else if keyFile != "" {
+		var err error
+		keyBytes, err = os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		_, keyBytes, err = multibase.Decode(keyEncoded)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if isEncryptedKey(keyBytes) {
+		pw, err := password()
+		if err != nil {
+			return nil, err
+		}
+		if keyBytes, err = decryptKey(keyBytes, pw); err != nil {
+			return nil, err
+		}
+	}
+	return unmarshalPrivateKey(keyBytes)
+}
+
+// signIPNSRecord creates an IPNS record with the embedded public key, if needed, and returns the marshalled record.
+// A nil ttl leaves the TTL field out of the record rather than setting it to 0, extra adds non-standard fields to the CBOR data.
+func signIPNSRecord(seqno int64, ttl *time.Duration, eol time.Time, value string, extra map[string]string, privKey crypto.PrivKey) ([]byte, error) {
+	log.Debugw("signing record", "value", value, "seqno", seqno, "eol", eol, "ttl", ttl, "keyType", privKey.Type())
+	var ttlValue time.Duration
+	if ttl != nil {
+		ttlValue = *ttl
+	}
+	rec, err := ipns.Create(privKey, []byte(value), uint64(seqno), eol, ttlValue)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the protobuf TTL can be left out. The V1 signature doesn't cover it, and the V2 CBOR data always has a TTL,
+	// 0 here, which a missing protobuf TTL reads as so the record still validates
+	if ttl == nil {
+		rec.Ttl = nil
+	}
+
+	if err := addExtraCBORFields(rec, extra, privKey); err != nil {
+		return nil, err
+	}
 
 	pub := privKey.GetPublic()
 	if err := ipns.EmbedPublicKey(pub, rec); err != nil {
-		return err
+		return nil, err
+	}
+
+	return rec.Marshal()
+}
+
+// recordStats describe the cost of creating a record
+type recordStats struct {
+	KeyType       string
+	SigningTimeNs int64
+	Size          int
+}
+
+// maxTTLFromEOL caps the TTL set by --ttl-from-eol, caching a record for longer than this makes updates take too long to be seen
+const maxTTLFromEOL = 7 * 24 * time.Hour
+
+// ttlFromEOL returns the time left until the EOL, rounded to the second, to use as the record's TTL.
+// It is capped at maxTTLFromEOL with a warning.
+func ttlFromEOL(eol time.Time) (time.Duration, error) {
+	ttl := time.Until(eol).Round(time.Second)
+	if ttl <= 0 {
+		return 0, fmt.Errorf("cannot set the TTL from an EOL in the past (%s)", eol.UTC().Format(time.RFC3339))
+	}
+	if ttl > maxTTLFromEOL {
+		fmt.Fprintf(os.Stderr, "warning: the EOL is %v from now, capping the TTL at %v\n", ttl, maxTTLFromEOL)
+		return maxTTLFromEOL, nil
 	}
+	return ttl, nil
+}
 
-	recBytes, err := rec.Marshal()
+// createIPNSRecord signs a record and outputs it. If stats is not nil a line of JSON stats about the record is written to it.
+// A non-nil validityType re-signs the record with that validity type, see withValidityType.
+// fieldSet picks the optional fields for testing resolvers, see applyFieldSet.
+// A non-empty corrupt deliberately breaks the record for negative testing, see corruptRecord.
+func createIPNSRecord(seqno int64, ttl *time.Duration, eol time.Time, value string, extra map[string]string, privKey crypto.PrivKey, outputBase string, httpResponse bool, stats io.Writer, validityType *ipns_pb.IpnsEntry_ValidityType, fieldSet, corrupt string) error {
+	start := time.Now()
+	recBytes, err := signIPNSRecord(seqno, ttl, eol, value, extra, privKey)
 	if err != nil {
 		return err
 	}
+	if validityType != nil {
+		if recBytes, err = withValidityType(recBytes, privKey, *validityType); err != nil {
+			return err
+		}
+	}
+	if recBytes, err = applyFieldSet(recBytes, privKey.GetPublic(), fieldSet); err != nil {
+		return err
+	}
 
-	if outputBase != "" {
-		enc, err := multibase.EncoderByName(outputBase)
+	if corrupt != "" {
+		if recBytes, err = corruptRecord(recBytes, corrupt); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "warning: this record is INTENTIONALLY INVALID (corrupted %s), only use it to test that validators reject it\n", corrupt)
+	}
+
+	if stats != nil {
+		out, err := json.Marshal(recordStats{
+			KeyType:       privKey.Type().String(),
+			SigningTimeNs: time.Since(start).Nanoseconds(),
+			Size:          len(recBytes),
+		})
 		if err != nil {
 			return err
 		}
-		fmt.Println(enc.Encode(recBytes))
-		return nil
+		if _, err := fmt.Fprintln(stats, string(out)); err != nil {
+			return err
+		}
 	}
-	_, err = os.Stdout.Write(recBytes)
-	return err
+
+	return outputRecord(recBytes, ttl, outputBase, httpResponse)
 }
 
-func parseIPNSRecord(data []byte) error {
-	rec := &ipns_pb.IpnsEntry{}
-	if err := rec.Unmarshal(data); err != nil {
+// outputRecord writes the record to stdout as raw bytes, multibase encoded with outputBase, or as an HTTP response
+func outputRecord(recBytes []byte, ttl *time.Duration, outputBase string, httpResponse bool) error {
+	if httpResponse {
+		return writeHTTPResponse(os.Stdout, recBytes, ttl)
+	}
+
+	return writeOutput(recBytes, outputBase)
+}
+
+// writeOutput writes data to stdout encoded with outputBase, see writeEncoded
+func writeOutput(data []byte, outputBase string) error {
+	return writeEncoded(os.Stdout, data, outputBase)
+}
+
+// writeEncoded writes data to w encoded with outputBase.
+// An empty outputBase writes the raw bytes. The identity base (also accepted as \x00) writes the raw bytes
+// prefixed with the identity multibase code 0x00, so the output is still multibase and decodes like any other base.
+// Neither has a trailing newline since they're binary, the text bases are written as a line.
+func writeEncoded(w io.Writer, data []byte, outputBase string) error {
+	if outputBase == "" {
+		_, err := w.Write(data)
 		return err
 	}
+	if outputBase == `\x00` {
+		outputBase = "identity"
+	}
 
-	eol, err := ipns.GetEOL(rec)
+	enc, err := multibase.EncoderByName(outputBase)
 	if err != nil {
 		return err
 	}
+	if enc.Encoding() == multibase.Identity {
+		_, err = io.WriteString(w, enc.Encode(data))
+		return err
+	}
+	_, err = fmt.Fprintln(w, enc.Encode(data))
+	return err
+}
+
+// readInput interprets input according to inputType, which may be auto, bytes, multibase, or path.
+// Files read for the path input type are decompressed according to decompress to at most maxSize bytes, see maybeDecompress.
+func readInput(input, inputType, decompress string, maxSize int64) ([]byte, error) {
+	if inputType == "auto" {
+		inputType = detectInputType(input)
+		log.Infow("detected input type", "type", inputType)
+	}
+	log.Debugw("reading input", "type", inputType)
+	switch inputType {
+	case "bytes":
+		return []byte(input), nil
+	case "multibase":
+		_, data, err := multibase.Decode(input)
+		if err != nil {
+			return nil, err
+		}
+		return data, nil
+	case "path":
+		data, err := os.ReadFile(input)
+		if err != nil {
+			return nil, err
+		}
+		return maybeDecompress(data, input, decompress, maxSize)
+	default:
+		return nil, errors.New("must pass either a record file or encoded record to parse")
+	}
+}
+
+// detectInputType picks the input type for the auto input type: path if the input names an existing file,
+// multibase if it decodes as multibase, and bytes otherwise.
+func detectInputType(input string) string {
+	if fi, err := os.Stat(input); err == nil && fi.Mode().IsRegular() {
+		return "path"
+	}
+	if _, _, err := multibase.Decode(input); err == nil {
+		return "multibase"
+	}
+	return "bytes"
+}
+
+// maxDecompressedSize is the default bound on decompressed input, far above the 10KiB record limit so only a gzip bomb reaches it
+const maxDecompressedSize = 64 << 20
+
+// maybeDecompress decompresses data read from the file at path based on the mode, which may be none, gzip, or auto.
+// In auto mode the data is decompressed if it starts with the gzip magic bytes or the file has a .gz extension.
+// Decompressing to more than maxSize bytes, or maxDecompressedSize when it is 0, is an error.
+func maybeDecompress(data []byte, path, mode string, maxSize int64) ([]byte, error) {
+	switch mode {
+	case "none":
+		return data, nil
+	case "gzip":
+	case "auto":
+		if !bytes.HasPrefix(data, gzipMagic) && filepath.Ext(path) != ".gz" {
+			return data, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown decompression %q, may be: none, gzip, or auto", mode)
+	}
+
+	log.Debugw("decompressing gzip input", "path", path)
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	if maxSize <= 0 {
+		maxSize = maxDecompressedSize
+	}
+	out, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(out)) > maxSize {
+		return nil, fmt.Errorf("%s decompresses to more than the maximum size of %d bytes", path, maxSize)
+	}
+	return out, nil
+}
+
+func readIPNSRecordFile(path, decompress string) (*ipns_pb.IpnsEntry, error) {
+	data, err := readInput(path, "path", decompress, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	rec, err := unmarshalIPNSRecord(data)
+	if err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// parsedRecord is the output of parsing an IPNS record, fields are printed in this order.
+// EOL is RFC3339 in UTC unless parse record --time-format says otherwise.
+// TTL is null when the record doesn't have one, which is different from an explicit 0.
+// The EOLRFC3339 and EOLUnix fields are only filled in by addEpochEOL, and Shape only with parse record --proto-version.
+type parsedRecord struct {
+	Value          string
+	Path           valuePath
+	SequenceNumber uint64
+	EOL            string
+	EOLRFC3339     string `json:",omitempty"`
+	EOLUnix        *int64 `json:",omitempty"`
+	EOLUnixNano    *int64 `json:",omitempty"`
+	TTL            *string
+	DataTTL        *string `json:",omitempty"`
+	PubKey         string
+	ExtraFields    map[string]json.RawMessage `json:",omitempty"`
+	Shape          *recordShape               `json:",omitempty"`
+	Publisher      *publisherGuess            `json:",omitempty"`
+
+	eol       time.Time
+	shape     recordShape
+	publisher publisherGuess
+	keyType   string
+}
+
+// addEpochEOL adds the EOL as RFC3339 and as seconds and nanoseconds since the Unix epoch, for tools that can't parse EOL
+func (p *parsedRecord) addEpochEOL() {
+	secs, nanos := p.eol.Unix(), p.eol.UnixNano()
+	p.EOLRFC3339 = p.eol.Format(time.RFC3339Nano)
+	p.EOLUnix = &secs
+	p.EOLUnixNano = &nanos
+}
+
+// decodeIPNSRecord parses the record, CIDs in its value are encoded with cidBase
+func decodeIPNSRecord(data []byte, cidBase string) (*parsedRecord, error) {
+	rec, err := unmarshalIPNSRecord(data)
+	if err != nil {
+		return nil, err
+	}
+
+	eol, err := ipns.GetEOL(rec)
+	if err != nil {
+		return nil, err
+	}
 
-	var ttl time.Duration
+	var ttl *string
 	if rec.Ttl != nil {
-		ttl = time.Duration(*rec.Ttl)
+		s := time.Duration(*rec.Ttl).String()
+		ttl = &s
+	}
+
+	// V2 records always sign a TTL in the CBOR data, it's shown when the protobuf TTL is missing or differs from it
+	var dataTTL *string
+	signedTTL, err := cborDataTTL(rec.GetData())
+	if err != nil {
+		return nil, err
+	}
+	if signedTTL != nil && (rec.Ttl == nil || time.Duration(rec.GetTtl()) != *signedTTL) {
+		s := signedTTL.String()
+		dataTTL = &s
+	}
+
+	extra, err := extraCBORFields(rec.GetData())
+	if err != nil {
+		return nil, err
 	}
 
-	pubKeyString := ""
+	pubKeyString, keyType := "", ""
 
 	if len(rec.PubKey) > 0 {
 		pubKeyString, err = multibase.Encode(multibase.Base16, rec.PubKey)
+		if err != nil {
+			return nil, err
+		}
+		if pub, err := crypto.UnmarshalPublicKey(rec.PubKey); err == nil {
+			keyType = pub.Type().String()
+		}
+	}
+
+	return &parsedRecord{
+		Value:          string(rec.Value),
+		Path:           parseValuePath(string(rec.Value), cidBase),
+		SequenceNumber: rec.GetSequence(),
+		EOL:            formatTime(eol, defaultTimeFormat),
+		TTL:            ttl,
+		DataTTL:        dataTTL,
+		PubKey:         pubKeyString,
+		ExtraFields:    extra,
+		eol:            eol,
+		shape:          newRecordShape(rec),
+		publisher:      identifyPublisher(rec),
+		keyType:        keyType,
+	}, nil
+}
+
+// parseRoutingJSONRecords prints the records from a multi-record routing JSON response as a JSON array.
+// Validating and --env only work with a single record, so they are rejected here.
+func parseRoutingJSONRecords(records [][]byte, c *cli.Context) error {
+	if c.Bool("validate") || c.Bool("env") || c.Bool("reconstruct-args") {
+		return fmt.Errorf("the routing JSON response contains %d records, --validate, --env, and --reconstruct-args only work with a single record", len(records))
+	}
+
+	results := make([]*parsedRecord, 0, len(records))
+	for _, recordBytes := range records {
+		if err := checkInputSize(recordBytes, c.Generic("max-size").(*sizeValue)); err != nil {
+			return err
+		}
+		rec, err := decodeIPNSRecord(recordBytes, c.String("cid-base"))
 		if err != nil {
 			return err
 		}
+		applyParseOptions(rec, c)
+		results = append(results, rec)
 	}
+	return printJSON(results, c.Bool("compact"))
+}
 
-	fmt.Printf(`
-{
-    "Value": "%s",
-    "SequenceNumber" : %d,
-    "EOL" : "%v",
-    "TTL" : "%v",
-    "PubKey" : "%s"
+// applyParseOptions applies the parse record flags that change how a parsed record is output
+func applyParseOptions(rec *parsedRecord, c *cli.Context) {
+	if c.IsSet("time-format") {
+		rec.EOL = formatTime(rec.eol, c.String("time-format"))
+	}
+	if c.Bool("since-epoch") {
+		rec.addEpochEOL()
+	}
+	if c.Bool("redact") {
+		rec.redact()
+	}
+	if c.Bool("proto-version") {
+		rec.Shape = &rec.shape
+	}
+	if c.Bool("identify-publisher") {
+		rec.Publisher = &rec.publisher
+	}
 }
 
-`, rec.Value, *rec.Sequence, eol, ttl, pubKeyString,
-	)
+// printJSON prints v as indented JSON, or on a single line when compact.
+// Struct fields keep their declared order and map keys are sorted.
+func printJSON(v interface{}, compact bool) error {
+	var out []byte
+	var err error
+	if compact {
+		out, err = json.Marshal(v)
+	} else {
+		out, err = json.MarshalIndent(v, "", "    ")
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
 	return nil
 }
 
-func parselibp2pkey(data []byte, isPrivateKey bool) error {
+// parsedKey is the output of parsing a libp2p key
+type parsedKey struct {
+	PrivateKey  bool   `json:"Private Key"`
+	KeyType     string `json:"Key Type"`
+	KeyMaterial string `json:"Key Material"`
+}
+
+func parselibp2pkey(data []byte, isPrivateKey, compact bool) error {
 	var keyType crypto_pb.KeyType
 	var keyMaterial []byte
 
 	if isPrivateKey {
-		privKey, err := crypto.UnmarshalPrivateKey(data)
+		privKey, err := unmarshalPrivateKey(data)
 		if err != nil {
 			return err
 		}
@@ -533,58 +3184,134 @@ func parselibp2pkey(data []byte, isPrivateKey bool) error {
 		return err
 	}
 
-	fmt.Printf(`
-{
-	"Private Key" : %t,
-	"Key Type": "%s",
-	"Key Material" : "%s",
+	return printJSON(parsedKey{
+		PrivateKey:  isPrivateKey,
+		KeyType:     keyType.String(),
+		KeyMaterial: keyMaterialString,
+	}, compact)
+}
+
+// fingerprintLength is the number of bytes of the public key hash kept in a key fingerprint
+const fingerprintLength = 10
+
+// keyFingerprint returns a short, stable identifier for the key.
+// It is a truncated sha2-256 multihash of the marshalled public key, base32 encoded.
+func keyFingerprint(pub crypto.PubKey) (string, error) {
+	pubBytes, err := crypto.MarshalPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+
+	mh, err := multihash.Sum(pubBytes, multihash.SHA2_256, fingerprintLength)
+	if err != nil {
+		return "", err
+	}
+	return multibase.Encode(multibase.Base32, mh)
 }
 
-`, isPrivateKey, keyType, keyMaterialString,
-	)
+func printKeyFingerprint(data []byte, isPrivateKey bool) error {
+	var pub crypto.PubKey
+	if isPrivateKey {
+		privKey, err := unmarshalPrivateKey(data)
+		if err != nil {
+			return err
+		}
+		pub = privKey.GetPublic()
+	} else {
+		var err error
+		pub, err = crypto.UnmarshalPublicKey(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	fingerprint, err := keyFingerprint(pub)
+	if err != nil {
+		return err
+	}
+	fmt.Println(fingerprint)
 	return nil
 }
 
 func getPubSubTopic(ipnsKey string) (string, error) {
-	c, err := cid.Decode(ipnsKey)
+	pid, err := decodeIPNSName(ipnsKey)
 	if err != nil {
 		return "", err
 	}
 
-	switch c.Version() {
-	case 0:
-		key := "/ipns/" + c.KeyString()
-		return psr.KeyToTopic(key), nil
-	case 1:
-		key := "/ipns/" + string(c.Hash())
-		return psr.KeyToTopic(key), nil
-	default:
-		return "", fmt.Errorf("IPNS key has unsupported CID version %d", c.Version())
-	}
+	return psr.KeyToTopic(ipns.RecordKey(pid)), nil
 }
 
 func getIPNSKey(topic string, cidVersion int) (string, error) {
-	topic = topic[len("/record/"):]
-	decoded, err := base64.RawURLEncoding.DecodeString(topic)
+	name, err := topicIPNSName(topic)
 	if err != nil {
 		return "", err
 	}
 
-	decoded = decoded[len("/ipns/"):]
-	c, err := cid.Cast(decoded)
+	c, err := ipnsNameCID(multihash.Multihash(name), cidVersion)
 	if err != nil {
 		return "", err
 	}
+	return c.String(), nil
+}
 
+// ipnsNameCID returns the IPNS name for the multihash of a peer ID as a CIDv0 or as a CIDv1 with the libp2p-key codec
+func ipnsNameCID(mh multihash.Multihash, cidVersion int) (cid.Cid, error) {
 	switch cidVersion {
 	case 0:
-		return c.String(), nil
+		decoded, err := multihash.Decode(mh)
+		if err != nil {
+			return cid.Undef, err
+		}
+		if decoded.Code != multihash.SHA2_256 {
+			return cid.Undef, fmt.Errorf("only names hashed with sha2-256 have a CIDv0, not %s (e.g. Ed25519 names, which inline the key)", multihash.Codes[decoded.Code])
+		}
+		return cid.NewCidV0(mh), nil
 	case 1:
-		c = cid.NewCidV1(cid.Libp2pKey, c.Hash())
-		return c.String(), nil
+		return cid.NewCidV1(cid.Libp2pKey, mh), nil
 	default:
-		return "", fmt.Errorf("could not output IPNS Key as unsupported CID version %d", cidVersion)
+		return cid.Undef, fmt.Errorf("could not output IPNS Key as unsupported CID version %d", cidVersion)
+	}
+}
+
+// convertIPNSName re-encodes an IPNS name as a CIDv0, which is always base58btc, or as a libp2p-key CIDv1 in outputBase.
+// Names that inline the key (e.g. Ed25519) have no CIDv0, v0 gives their legacy base58btc peer ID (12D3KooW...) instead.
+// CIDv1 names with a codec other than libp2p-key are rejected rather than silently converted.
+func convertIPNSName(name string, cidVersion int, outputBase string) (string, error) {
+	pid, err := decodeIPNSName(name)
+	if err != nil {
+		return "", err
+	}
+
+	if cidVersion == 0 {
+		if outputBase != "" && outputBase != "base58btc" && outputBase != "z" {
+			return "", fmt.Errorf("a CIDv0 is always base58btc, it can't be encoded in %s", outputBase)
+		}
+		// the same string as the CIDv0 for sha2-256 names
+		return peer.Encode(pid), nil
+	}
+
+	c, err := ipnsNameCID(multihash.Multihash(pid), cidVersion)
+	if err != nil {
+		return "", err
+	}
+	return encodeCIDString(c.String(), outputBase)
+}
+
+// encodeCIDString re-encodes the CID in the multibase, an empty outputBase leaves it as it is
+func encodeCIDString(s, outputBase string) (string, error) {
+	if outputBase == "" {
+		return s, nil
+	}
+	enc, err := multibase.EncoderByName(outputBase)
+	if err != nil {
+		return "", err
+	}
+	c, err := cid.Decode(s)
+	if err != nil {
+		return "", err
 	}
+	return encodeCID(c, enc.Encoding())
 }
 
 func getDHTRendezvousKey(topic string) (string, error) {
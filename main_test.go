@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+)
+
+func TestWriteEncoded(t *testing.T) {
+	data := []byte{0x0a, 0x00, 0xff, 'h', 'i'}
+	tests := []struct {
+		base string
+		want []byte
+	}{
+		// no base is the raw bytes, not multibase
+		{"", data},
+		// identity is multibase, the 0x00 prefix and then the raw bytes
+		{"identity", append([]byte{0x00}, data...)},
+		{`\x00`, append([]byte{0x00}, data...)},
+		{"\x00", append([]byte{0x00}, data...)},
+		// text bases are written as a line
+		{"base16", []byte("f0a00ff6869\n")},
+		{"f", []byte("f0a00ff6869\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.base, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeEncoded(&buf, data, tt.base); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), tt.want) {
+				t.Errorf("got %x, want %x", buf.Bytes(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteEncodedUnknownBase(t *testing.T) {
+	if err := writeEncoded(&bytes.Buffer{}, []byte("x"), "base99"); err == nil {
+		t.Fatal("expected an error for an unknown base")
+	}
+}
+
+func TestMaybeDecompressLimit(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(make([]byte, 1<<20)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a MiB of zeros compresses to about a KiB, the bound is on the decompressed size
+	if _, err := maybeDecompress(buf.Bytes(), "r.gz", "auto", 10<<10); err == nil {
+		t.Fatal("expected an error decompressing past the maximum size")
+	}
+	got, err := maybeDecompress(buf.Bytes(), "r.gz", "auto", 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1<<20 {
+		t.Fatalf("decompressed %d bytes, want %d", len(got), 1<<20)
+	}
+	if _, err := maybeDecompress(buf.Bytes(), "r.gz", "gzip", 0); err != nil {
+		t.Fatalf("the default bound rejected a MiB: %v", err)
+	}
+}
+
+func TestGetIPNSKeyBadTopics(t *testing.T) {
+	for _, topic := range []string{"abc", "/record/", "/record/!!", "/record/L2lwbnMv", "/record/YWJj"} {
+		if _, err := getIPNSKey(topic, 1); err == nil {
+			t.Errorf("expected an error for topic %q", topic)
+		}
+	}
+
+	name := "QmdygNbVyLEXiekrk6rYNi3hCaN7ZPNtr6BYQaJik2cKHY"
+	topic, err := getPubSubTopic(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := getIPNSKey(topic, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != name {
+		t.Fatalf("got %s, want %s", got, name)
+	}
+}
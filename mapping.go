@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// mappingManifestFile is the name of the manifest create mapped-records writes next to the records
+const mappingManifestFile = "manifest.json"
+
+// mappingEntry is a line of a mapping file: the key to sign with, as an IPNS name or a key file, and the value to publish
+type mappingEntry struct {
+	line  int
+	key   string
+	value string
+}
+
+// mappedRecord is an entry of the manifest written by createMappedRecords.
+// Key is the key column of the mapping line, Record is the record's file name in the output directory.
+type mappedRecord struct {
+	Name           string
+	Key            string
+	Value          string
+	SequenceNumber int64
+	EOL            time.Time
+	TTL            *string
+	Record         string
+}
+
+// readMappingFile reads lines of `<name-or-key-file> <value>`, optionally separated by -> instead of whitespace.
+// Blank lines and lines starting with # are ignored.
+func readMappingFile(path string) ([]mappingEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []mappingEntry
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(strings.Replace(text, "->", " ", 1))
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s line %d: expected `<name-or-key-file> <value>`, got %q", path, line, text)
+		}
+		entries = append(entries, mappingEntry{line: line, key: fields[0], value: fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s has no entries", path)
+	}
+	return entries, nil
+}
+
+// readKeyDir reads every private key in dir by its IPNS name, other files (e.g. public keys) are skipped
+func readKeyDir(dir string) (map[peer.ID]crypto.PrivKey, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[peer.ID]crypto.PrivKey)
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, f.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		priv, err := unmarshalPrivateKey(data)
+		if err != nil {
+			log.Debugw("skipping file in key directory", "path", path, "error", err)
+			continue
+		}
+		name, err := peer.IDFromPrivateKey(priv)
+		if err != nil {
+			return nil, err
+		}
+		keys[name] = priv
+	}
+	log.Debugw("read key directory", "dir", dir, "keys", len(keys))
+	return keys, nil
+}
+
+// mappingKey returns the key for a mapping entry. An IPNS name is looked up in keys, anything else is a key file path,
+// relative to the mapping file's directory unless absolute.
+func mappingKey(e mappingEntry, mappingPath string, keys map[peer.ID]crypto.PrivKey) (crypto.PrivKey, error) {
+	if name, err := decodeIPNSName(e.key); err == nil {
+		if keys == nil {
+			return nil, fmt.Errorf("%s is an IPNS name, pass --key-dir to look up its key", e.key)
+		}
+		priv, ok := keys[name]
+		if !ok {
+			return nil, fmt.Errorf("there is no private key for %s in --key-dir", peer.ToCid(name))
+		}
+		return priv, nil
+	}
+
+	path := e.key
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(mappingPath), path)
+	}
+	return loadPrivateKey(path, "", nil)
+}
+
+// createMappedRecords signs a record for every entry in the mapping file with the entry's own key and writes each to
+// outDir as <name>.ipns-record, along with a manifest.json listing them. template gives the sequence number, EOL or
+// lifetime, and TTL shared by every record, its Value is ignored. Every key is found before anything is signed or written, and a name may
+// only be mapped once.
+func createMappedRecords(mappingPath, keyDir, outDir string, template manifestEntry) error {
+	entries, err := readMappingFile(mappingPath)
+	if err != nil {
+		return err
+	}
+	var keys map[peer.ID]crypto.PrivKey
+	if keyDir != "" {
+		if keys, err = readKeyDir(keyDir); err != nil {
+			return err
+		}
+	}
+
+	privs := make([]crypto.PrivKey, len(entries))
+	seen := make(map[peer.ID]int, len(entries))
+	for i, e := range entries {
+		priv, err := mappingKey(e, mappingPath, keys)
+		if err != nil {
+			return fmt.Errorf("%s line %d: %w", mappingPath, e.line, err)
+		}
+		name, err := peer.IDFromPrivateKey(priv)
+		if err != nil {
+			return err
+		}
+		if line, ok := seen[name]; ok {
+			return fmt.Errorf("%s line %d: %s is already mapped on line %d", mappingPath, e.line, peer.ToCid(name), line)
+		}
+		seen[name] = e.line
+		privs[i] = priv
+	}
+
+	eol, ttl, err := template.validity(time.Now())
+	if err != nil {
+		return err
+	}
+	var ttlString *string
+	if ttl != nil {
+		s := ttl.String()
+		ttlString = &s
+	}
+
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+	manifest := make([]mappedRecord, 0, len(entries))
+	for i, e := range entries {
+		recBytes, err := signIPNSRecord(template.SequenceNumber, ttl, eol, e.value, nil, privs[i])
+		if err != nil {
+			return fmt.Errorf("%s line %d: %w", mappingPath, e.line, err)
+		}
+
+		name, err := peer.IDFromPrivateKey(privs[i])
+		if err != nil {
+			return err
+		}
+		file := peer.ToCid(name).String() + ".ipns-record"
+		if err := os.WriteFile(filepath.Join(outDir, file), recBytes, 0o644); err != nil {
+			return err
+		}
+
+		manifest = append(manifest, mappedRecord{
+			Name:           peer.ToCid(name).String(),
+			Key:            e.key,
+			Value:          e.value,
+			SequenceNumber: template.SequenceNumber,
+			EOL:            eol.UTC(),
+			TTL:            ttlString,
+			Record:         file,
+		})
+	}
+
+	if err := writeJSONFile(filepath.Join(outDir, mappingManifestFile), manifest); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d records and %s to %s\n", len(manifest), mappingManifestFile, outDir)
+	return nil
+}
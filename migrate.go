@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-ipns"
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// migration is the output of migrating the records of one name to a new key
+type migration struct {
+	OldName string
+	NewName string
+	Value   string
+	Record  string
+}
+
+// migrateRecords re-issues the newest record in oldDir under newKey with the sequence number reset to 0.
+// A key only has one name, so the records in oldDir have to belong to a single name.
+// The new record is written to outDir as <new-name>.ipns-record.
+func migrateRecords(oldDir string, newKey crypto.PrivKey, outDir string, lifetime time.Duration, compact bool) error {
+	latest := make(map[peer.ID]*ipns_pb.IpnsEntry)
+	err := processDirectory(oldDir, true, func(path string) error {
+		rec, err := readIPNSRecordFile(path, "auto")
+		if err != nil {
+			return err
+		}
+		name, err := nameForRecordFile(path, rec)
+		if err != nil {
+			return err
+		}
+
+		if prev, ok := latest[name]; ok {
+			if newer, err := ipns.Compare(rec, prev); err != nil || newer <= 0 {
+				return err
+			}
+		}
+		latest[name] = rec
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	switch len(latest) {
+	case 0:
+		return fmt.Errorf("no records found in %s", oldDir)
+	case 1:
+	default:
+		var names []string
+		for name := range latest {
+			names = append(names, peer.ToCid(name).String())
+		}
+		sort.Strings(names)
+		return fmt.Errorf("found records for %d names but a key can only take over one, split the records by name: %s", len(names), strings.Join(names, ", "))
+	}
+
+	// take the only entry
+	var oldName peer.ID
+	var old *ipns_pb.IpnsEntry
+	for oldName, old = range latest {
+	}
+
+	var ttl *time.Duration
+	if old.Ttl != nil {
+		d := time.Duration(old.GetTtl())
+		ttl = &d
+	}
+	recBytes, err := signIPNSRecord(0, ttl, time.Now().Add(lifetime), string(old.GetValue()), nil, newKey)
+	if err != nil {
+		return err
+	}
+
+	newName, err := peer.IDFromPrivateKey(newKey)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+	outPath := filepath.Join(outDir, peer.ToCid(newName).String()+".ipns-record")
+	f, err := os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(recBytes); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return printJSON(migration{
+		OldName: peer.ToCid(oldName).String(),
+		NewName: peer.ToCid(newName).String(),
+		Value:   string(old.GetValue()),
+		Record:  outPath,
+	}, compact)
+}
@@ -0,0 +1,264 @@
+//go:build pkcs11
+// +build pkcs11
+
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	crypto_pb "github.com/libp2p/go-libp2p-core/crypto/pb"
+	"github.com/miekg/pkcs11"
+)
+
+// pkcs11Supported is whether this build can use PKCS#11 keys
+const pkcs11Supported = true
+
+// Ed25519 constants from PKCS#11 3.0, which the pkcs11 package predates
+const (
+	ckkECEdwards = 0x40
+	ckmEdDSA     = 0x1057
+)
+
+var (
+	oidP256      = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidSecp256k1 = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+)
+
+// pkcs11Key is a private key that stays in a PKCS#11 module, signing is delegated to the module.
+// The module session is left open until the process exits.
+type pkcs11Key struct {
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	handle  pkcs11.ObjectHandle
+	pub     crypto.PubKey
+}
+
+// loadPKCS11Key finds the private key with the label in the PKCS#11 module, logging in with the PIN.
+// The public key is read from the public key object with the same label.
+func loadPKCS11Key(module, label, pin string) (crypto.PrivKey, error) {
+	ctx := pkcs11.New(module)
+	if ctx == nil {
+		return nil, fmt.Errorf("could not load PKCS#11 module %s", module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		return nil, fmt.Errorf("could not initialize PKCS#11 module %s: %w", module, err)
+	}
+
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return nil, err
+	}
+	for _, slot := range slots {
+		session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.Login(session, pkcs11.CKU_USER, pin); err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+			ctx.CloseSession(session)
+			log.Debugw("could not log in to PKCS#11 slot", "slot", slot, "err", err)
+			continue
+		}
+
+		priv, err := findPKCS11Object(ctx, session, pkcs11.CKO_PRIVATE_KEY, label)
+		if err != nil {
+			ctx.CloseSession(session)
+			return nil, err
+		}
+		if priv == nil {
+			ctx.CloseSession(session)
+			continue
+		}
+
+		log.Infow("found PKCS#11 key", "slot", slot, "label", label)
+		pub, err := pkcs11PublicKey(ctx, session, label)
+		if err != nil {
+			ctx.CloseSession(session)
+			return nil, err
+		}
+		return &pkcs11Key{ctx: ctx, session: session, handle: *priv, pub: pub}, nil
+	}
+	return nil, fmt.Errorf("no private key labelled %q in PKCS#11 module %s", label, module)
+}
+
+// findPKCS11Object returns the object of the class with the label, nil if there isn't one
+func findPKCS11Object(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, class uint, label string) (*pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	if err := ctx.FindObjectsInit(session, template); err != nil {
+		return nil, err
+	}
+	objs, _, err := ctx.FindObjects(session, 2)
+	if finalErr := ctx.FindObjectsFinal(session); err == nil {
+		err = finalErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(objs) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &objs[0], nil
+	default:
+		return nil, fmt.Errorf("more than one PKCS#11 object labelled %q, labels must be unique", label)
+	}
+}
+
+// pkcs11PublicKey reads the public key labelled label as a libp2p key
+func pkcs11PublicKey(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, label string) (crypto.PubKey, error) {
+	obj, err := findPKCS11Object(ctx, session, pkcs11.CKO_PUBLIC_KEY, label)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, fmt.Errorf("no public key labelled %q next to the private key, it is needed for the IPNS name", label)
+	}
+
+	attrs, err := ctx.GetAttributeValue(session, *obj, []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil)})
+	if err != nil {
+		return nil, err
+	}
+	switch keyType := bytesToUint(attrs[0].Value); keyType {
+	case pkcs11.CKK_RSA:
+		attrs, err := ctx.GetAttributeValue(session, *obj, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+		})
+		if err != nil {
+			return nil, err
+		}
+		pub := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(attrs[0].Value),
+			E: int(new(big.Int).SetBytes(attrs[1].Value).Int64()),
+		}
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.UnmarshalRsaPublicKey(der)
+	case pkcs11.CKK_EC, ckkECEdwards:
+		attrs, err := ctx.GetAttributeValue(session, *obj, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+		})
+		if err != nil {
+			return nil, err
+		}
+		// CKA_EC_POINT is DER encoded as an OCTET STRING
+		point := attrs[1].Value
+		var unwrapped []byte
+		if rest, err := asn1.Unmarshal(point, &unwrapped); err == nil && len(rest) == 0 {
+			point = unwrapped
+		}
+
+		if keyType == ckkECEdwards {
+			return crypto.UnmarshalEd25519PublicKey(point)
+		}
+		var oid asn1.ObjectIdentifier
+		if _, err := asn1.Unmarshal(attrs[0].Value, &oid); err != nil {
+			return nil, fmt.Errorf("could not read the curve of PKCS#11 key %q: %w", label, err)
+		}
+		switch {
+		case oid.Equal(oidP256):
+			x, y := elliptic.Unmarshal(elliptic.P256(), point)
+			if x == nil {
+				return nil, fmt.Errorf("invalid P-256 point for PKCS#11 key %q", label)
+			}
+			der, err := x509.MarshalPKIXPublicKey(&ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y})
+			if err != nil {
+				return nil, err
+			}
+			return crypto.UnmarshalECDSAPublicKey(der)
+		case oid.Equal(oidSecp256k1):
+			return crypto.UnmarshalSecp256k1PublicKey(point)
+		default:
+			return nil, fmt.Errorf("PKCS#11 key %q is on unsupported curve %v, IPNS supports P-256 and secp256k1", label, oid)
+		}
+	default:
+		return nil, fmt.Errorf("PKCS#11 key %q has unsupported key type %#x", label, keyType)
+	}
+}
+
+// bytesToUint decodes a CK_ULONG attribute, which is in the native (little endian on supported platforms) byte order
+func bytesToUint(b []byte) uint {
+	var v uint
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint(b[i])
+	}
+	return v
+}
+
+// Sign signs data in the module the way libp2p signs with a key of the same type
+func (k *pkcs11Key) Sign(data []byte) ([]byte, error) {
+	switch k.pub.Type() {
+	case crypto_pb.KeyType_Ed25519:
+		return k.sign(ckmEdDSA, data)
+	case crypto_pb.KeyType_RSA:
+		return k.sign(pkcs11.CKM_SHA256_RSA_PKCS, data)
+	case crypto_pb.KeyType_ECDSA, crypto_pb.KeyType_Secp256k1:
+		hash := sha256.Sum256(data)
+		sig, err := k.sign(pkcs11.CKM_ECDSA, hash[:])
+		if err != nil {
+			return nil, err
+		}
+		if len(sig)%2 != 0 {
+			return nil, fmt.Errorf("unexpected ECDSA signature length %d from the PKCS#11 module", len(sig))
+		}
+		r := new(big.Int).SetBytes(sig[:len(sig)/2])
+		s := new(big.Int).SetBytes(sig[len(sig)/2:])
+		if k.pub.Type() == crypto_pb.KeyType_Secp256k1 {
+			// secp256k1 signatures use the low S form
+			if halfOrder := new(big.Int).Rsh(btcec.S256().N, 1); s.Cmp(halfOrder) > 0 {
+				s.Sub(btcec.S256().N, s)
+			}
+			return (&btcec.Signature{R: r, S: s}).Serialize(), nil
+		}
+		return asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	default:
+		return nil, fmt.Errorf("cannot sign with %s keys in a PKCS#11 module", k.pub.Type())
+	}
+}
+
+func (k *pkcs11Key) sign(mechanism uint, data []byte) ([]byte, error) {
+	if err := k.ctx.SignInit(k.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)}, k.handle); err != nil {
+		return nil, fmt.Errorf("PKCS#11 sign init failed: %w", err)
+	}
+	return k.ctx.Sign(k.session, data)
+}
+
+func (k *pkcs11Key) GetPublic() crypto.PubKey {
+	return k.pub
+}
+
+func (k *pkcs11Key) Type() crypto_pb.KeyType {
+	return k.pub.Type()
+}
+
+// Raw fails since the private key can't leave the module
+func (k *pkcs11Key) Raw() ([]byte, error) {
+	return nil, errors.New("the private key is in a PKCS#11 module and cannot be exported")
+}
+
+func (k *pkcs11Key) Equals(o crypto.Key) bool {
+	other, ok := o.(*pkcs11Key)
+	if !ok {
+		return false
+	}
+	a, errA := crypto.MarshalPublicKey(k.pub)
+	b, errB := crypto.MarshalPublicKey(other.pub)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
@@ -0,0 +1,18 @@
+//go:build !pkcs11
+// +build !pkcs11
+
+package main
+
+import (
+	"errors"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+)
+
+// pkcs11Supported is whether this build can use PKCS#11 keys
+const pkcs11Supported = false
+
+// loadPKCS11Key is only available in builds with the pkcs11 tag, which need CGo
+func loadPKCS11Key(module, label, pin string) (crypto.PrivKey, error) {
+	return nil, errors.New("this build has no PKCS#11 support, rebuild with: go build -tags pkcs11")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"gopkg.in/yaml.v2"
+)
+
+// keyPolicy is the set of rules a key has to follow, unset rules are not checked
+type keyPolicy struct {
+	AllowedTypes []string `yaml:"allowed-types"`
+	RSAMinBits   int      `yaml:"rsa-min-bits"`
+	RSAMaxBits   int      `yaml:"rsa-max-bits"`
+}
+
+// readKeyPolicy reads a YAML (or JSON) policy file
+func readKeyPolicy(path string) (*keyPolicy, error) {
+	if path == "" {
+		return nil, errors.New("no policy specified, pass it with --policy")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &keyPolicy{}
+	if err := yaml.UnmarshalStrict(data, p); err != nil {
+		return nil, fmt.Errorf("could not parse policy file %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// check returns an error naming the first rule the key breaks
+func (p *keyPolicy) check(priv crypto.PrivKey) error {
+	keyType := strings.ToLower(priv.Type().String())
+	if len(p.AllowedTypes) > 0 {
+		allowed := false
+		for _, t := range p.AllowedTypes {
+			if strings.EqualFold(t, keyType) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("policy rule allowed-types failed: key type %s is not one of %s", keyType, strings.Join(p.AllowedTypes, ", "))
+		}
+	}
+
+	if priv.Type() != crypto.RSA {
+		return nil
+	}
+	stdKey, err := crypto.PrivKeyToStdKey(priv)
+	if err != nil {
+		return err
+	}
+	rsaKey, ok := stdKey.(*rsa.PrivateKey)
+	if !ok {
+		return fmt.Errorf("unexpected RSA key type %T", stdKey)
+	}
+	bits := rsaKey.N.BitLen()
+	if p.RSAMinBits > 0 && bits < p.RSAMinBits {
+		return fmt.Errorf("policy rule rsa-min-bits failed: key has %d bits, the minimum is %d", bits, p.RSAMinBits)
+	}
+	if p.RSAMaxBits > 0 && bits > p.RSAMaxBits {
+		return fmt.Errorf("policy rule rsa-max-bits failed: key has %d bits, the maximum is %d", bits, p.RSAMaxBits)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gogo/protobuf/proto"
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+)
+
+// recordFromPrototext returns the binary record for an IpnsEntry in protobuf text format, as older dumps and logs store them,
+// e.g. `value: "/ipfs/..." signatureV1: "\x12\xa4..."`. The bytes fields use the text format's C-style escapes
+// (\xNN, octal \NNN, \n, ...), which are decoded back to the raw bytes.
+func recordFromPrototext(data []byte) ([]byte, error) {
+	rec := &ipns_pb.IpnsEntry{}
+	if err := proto.UnmarshalText(string(data), rec); err != nil {
+		return nil, fmt.Errorf("could not parse the protobuf text format record: %w", err)
+	}
+	return rec.Marshal()
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/ipfs/go-ipns"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// provenanceContext is the JSON-LD context of provenance documents, it maps the plain field names to IRIs
+const provenanceContext = "https://github.com/aschmahmann/ipns-utils/provenance#"
+
+// provenanceSignaturePrefix is prepended to the document when signing so the signature can't be mistaken for any other
+const provenanceSignaturePrefix = "ipns-utils-provenance:"
+
+// provenanceDocument is a self-describing archive of a record: the record itself, the fields it was created with,
+// and where it came from. The optional signature covers the JSON encoding of every other field and is separate
+// from the record's own signatures.
+type provenanceDocument struct {
+	Context        map[string]string `json:"@context"`
+	Type           string            `json:"@type"`
+	Name           string
+	Value          string
+	SequenceNumber uint64
+	EOL            string
+	Record         string
+	Created        string
+	Tool           string
+	ToolVersion    string
+	PubKey         string               `json:",omitempty"`
+	Signature      *provenanceSignature `json:",omitempty"`
+}
+
+// provenanceSignature is the signature over a provenance document
+type provenanceSignature struct {
+	KeyType string
+	Value   string
+}
+
+// toolVersion returns the version of ipns-utils from the build info, (devel) for local builds
+func toolVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Version
+	}
+	return "unknown"
+}
+
+// signingBytes returns what the document signature is computed over, the document without its signature
+func (d provenanceDocument) signingBytes() ([]byte, error) {
+	d.Signature = nil
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(provenanceSignaturePrefix), data...), nil
+}
+
+// createProvenance outputs a provenance document for the record, which must be signed by priv.
+// With sign the document is also signed by priv.
+func createProvenance(recordBytes []byte, priv crypto.PrivKey, sign, compact bool) error {
+	rec, err := unmarshalIPNSRecord(recordBytes)
+	if err != nil {
+		return err
+	}
+	name, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		return err
+	}
+	if err := checkEmbeddedPublicKey(name, rec); err != nil {
+		return err
+	}
+	if err := ipns.Validate(priv.GetPublic(), rec); err != nil && !errors.Is(err, ipns.ErrExpiredRecord) {
+		return fmt.Errorf("the record is not signed by the key: %w", err)
+	}
+	eol, err := ipns.GetEOL(rec)
+	if err != nil {
+		return err
+	}
+
+	doc := provenanceDocument{
+		Context:        map[string]string{"@vocab": provenanceContext},
+		Type:           "IPNSRecordProvenance",
+		Name:           peer.ToCid(name).String(),
+		Value:          string(rec.GetValue()),
+		SequenceNumber: rec.GetSequence(),
+		EOL:            eol.UTC().Format(time.RFC3339Nano),
+		Record:         base64.StdEncoding.EncodeToString(recordBytes),
+		Created:        time.Now().UTC().Format(time.RFC3339),
+		Tool:           "ipns-utils",
+		ToolVersion:    toolVersion(),
+	}
+
+	if sign {
+		pubBytes, err := crypto.MarshalPublicKey(priv.GetPublic())
+		if err != nil {
+			return err
+		}
+		doc.PubKey = base64.StdEncoding.EncodeToString(pubBytes)
+
+		data, err := doc.signingBytes()
+		if err != nil {
+			return err
+		}
+		sig, err := priv.Sign(data)
+		if err != nil {
+			return err
+		}
+		doc.Signature = &provenanceSignature{
+			KeyType: priv.Type().String(),
+			Value:   base64.StdEncoding.EncodeToString(sig),
+		}
+	}
+	return printJSON(doc, compact)
+}
+
+// verifyProvenance checks a provenance document: the record is authentic for the name (expiry is only reported, archived
+// records are usually expired), the document's fields match the record, and the document signature if it has one.
+func verifyProvenance(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var doc provenanceDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("could not parse provenance document: %w", err)
+	}
+
+	name, err := decodeIPNSName(doc.Name)
+	if err != nil {
+		return err
+	}
+	recordBytes, err := base64.StdEncoding.DecodeString(doc.Record)
+	if err != nil {
+		return fmt.Errorf("could not decode the record: %w", err)
+	}
+	rec, err := unmarshalIPNSRecord(recordBytes)
+	if err != nil {
+		return err
+	}
+
+	var pub crypto.PubKey
+	if doc.PubKey != "" {
+		pubBytes, err := base64.StdEncoding.DecodeString(doc.PubKey)
+		if err != nil {
+			return fmt.Errorf("could not decode the document's public key: %w", err)
+		}
+		if pub, err = crypto.UnmarshalPublicKey(pubBytes); err != nil {
+			return err
+		}
+		if pid, err := peer.IDFromPublicKey(pub); err != nil || pid != name {
+			return fmt.Errorf("the document's public key does not belong to %s", doc.Name)
+		}
+	} else if pub, err = ipns.ExtractPublicKey(name, rec); err != nil {
+		return err
+	}
+
+	if err := checkEmbeddedPublicKey(name, rec); err != nil {
+		return err
+	}
+	expired := false
+	if err := ipns.Validate(pub, rec); errors.Is(err, ipns.ErrExpiredRecord) {
+		expired = true
+	} else if err != nil {
+		return fmt.Errorf("the record is not valid for %s: %w", doc.Name, err)
+	}
+
+	eol, err := ipns.GetEOL(rec)
+	if err != nil {
+		return err
+	}
+	if doc.Value != string(rec.GetValue()) || doc.SequenceNumber != rec.GetSequence() || doc.EOL != eol.UTC().Format(time.RFC3339Nano) {
+		return errors.New("the document's value, sequence number, or EOL do not match its record")
+	}
+
+	signed := "unsigned"
+	if doc.Signature != nil {
+		if err := checkProvenanceSignature(doc, pub); err != nil {
+			return err
+		}
+		signed = "signed"
+	}
+
+	status := "valid"
+	if expired {
+		status = "valid, the record has expired"
+	}
+	fmt.Printf("%s: %s, %s document for %s created %s\n", path, status, signed, doc.Name, doc.Created)
+	return nil
+}
+
+// checkProvenanceSignature verifies the document signature with the public key of the name
+func checkProvenanceSignature(doc provenanceDocument, pub crypto.PubKey) error {
+	sig, err := base64.StdEncoding.DecodeString(doc.Signature.Value)
+	if err != nil {
+		return fmt.Errorf("could not decode the document signature: %w", err)
+	}
+	data, err := doc.signingBytes()
+	if err != nil {
+		return err
+	}
+	ok, err := pub.Verify(data, sig)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("the document signature is invalid, the document was modified after it was signed")
+	}
+	return nil
+}
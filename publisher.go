@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// publisherGuess is the implementation that likely published a record and the evidence the guess is based on
+type publisherGuess struct {
+	Publisher  string
+	Confidence string
+	Evidence   []string
+}
+
+const (
+	publisherKubo   = "Kubo (go-ipns or boxo)"
+	publisherW3name = "w3name (js-ipns)"
+)
+
+// eolFraction returns the fractional seconds digits of the record's validity, e.g. "431000000" for ...:42.431Z
+func eolFraction(validity string) string {
+	t := strings.IndexByte(validity, 'T')
+	dot := strings.IndexByte(validity, '.')
+	if t < 0 || dot < t {
+		return ""
+	}
+	end := strings.IndexAny(validity[dot+1:], "Zz+-")
+	if end < 0 {
+		return validity[dot+1:]
+	}
+	return validity[dot+1 : dot+1+end]
+}
+
+// identifyPublisher guesses whether Kubo or w3name published the record from the habits of their IPNS libraries.
+// Go formats the EOL with time.RFC3339Nano, which trims trailing zeros, while js-ipns always writes nine digits from
+// a millisecond clock. go-ipns only embeds public keys that are too large to inline in the name, Kubo sets a TTL,
+// and V1-only records predate go-ipns v0.1.0. The weaker signals only count as evidence, not as a verdict.
+func identifyPublisher(rec *ipns_pb.IpnsEntry) publisherGuess {
+	var kubo, w3name int
+	guess := publisherGuess{Evidence: []string{}}
+	note := func(format string, args ...interface{}) {
+		guess.Evidence = append(guess.Evidence, fmt.Sprintf(format, args...))
+	}
+
+	switch frac := eolFraction(string(rec.GetValidity())); {
+	case frac == "":
+		note("the EOL has no fractional seconds, which neither library writes unless the time falls on a whole second")
+	case len(frac) == 9 && strings.HasSuffix(frac, "000000"):
+		w3name += 2
+		note("the EOL has nine fractional digits ending in six zeros, a millisecond clock padded to nanoseconds as js-ipns does")
+	case !strings.HasSuffix(frac, "0"):
+		kubo += 2
+		note("the EOL has %d fractional digits with trailing zeros trimmed, as Go's time.RFC3339Nano formats it", len(frac))
+	default:
+		note("the EOL has %d fractional digits with trailing zeros kept, which matches neither library's formatting", len(frac))
+	}
+
+	if rec.Ttl == nil {
+		w3name++
+		note("the record has no TTL, Kubo sets one on every record it publishes")
+	} else {
+		kubo++
+		note("the record has a TTL of %s", time.Duration(rec.GetTtl()))
+	}
+
+	switch newRecordShape(rec).Signatures {
+	case "v1":
+		kubo++
+		note("the record only has a V1 signature, as go-ipns before v0.1.0 (Kubo before v0.9) wrote")
+	case "v2":
+		note("the record only has a V2 signature, as recent versions of both libraries can be configured to write")
+	case "v1+v2":
+		note("the record has V1 and V2 signatures, as both libraries write by default")
+	}
+
+	if len(rec.PubKey) > 0 {
+		if pub, err := crypto.UnmarshalPublicKey(rec.PubKey); err == nil {
+			inlined := false
+			if id, err := peer.IDFromPublicKey(pub); err == nil {
+				_, err := id.ExtractPublicKey()
+				inlined = err == nil
+			}
+			if inlined {
+				w3name++
+				note("the record embeds its %s public key although it fits in the name, go-ipns only embeds keys that don't", pub.Type())
+			} else {
+				note("the record embeds its %s public key, which is too large to inline in the name so every library embeds it", pub.Type())
+			}
+		}
+	}
+
+	if extra, err := extraCBORFields(rec.GetData()); err == nil && len(extra) > 0 {
+		note("the CBOR data has %d non-standard fields, which neither library writes, it may come from a custom publisher", len(extra))
+	}
+
+	diff := kubo - w3name
+	switch {
+	case diff > 0:
+		guess.Publisher = publisherKubo
+	case diff < 0:
+		guess.Publisher = publisherW3name
+		diff = -diff
+	default:
+		guess.Publisher = "unknown"
+	}
+	switch {
+	case diff >= 3:
+		guess.Confidence = "high"
+	case diff >= 1:
+		guess.Confidence = "low"
+	default:
+		guess.Confidence = "none"
+	}
+	return guess
+}
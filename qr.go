@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multibase"
+	"github.com/skip2/go-qrcode"
+)
+
+// qrPNGSize is the width and height in pixels of the PNG written by --qr-out
+const qrPNGSize = 512
+
+// nameInfo is the output of inspect name, the forms the name is written in and the key type when the name inlines the key
+type nameInfo struct {
+	Name    string
+	Base36  string
+	PeerID  string
+	URI     string
+	KeyType string `json:",omitempty"`
+}
+
+// ipnsURI returns the canonical ipns:// URI of the name, using the base36 CIDv1 that also fits in a DNS label
+func ipnsURI(name peer.ID) (string, error) {
+	s, err := peer.ToCid(name).StringOfBase(multibase.Base36)
+	if err != nil {
+		return "", err
+	}
+	return "ipns://" + s, nil
+}
+
+// inspectName returns the forms of the IPNS name
+func inspectName(name peer.ID) (*nameInfo, error) {
+	base36, err := peer.ToCid(name).StringOfBase(multibase.Base36)
+	if err != nil {
+		return nil, err
+	}
+	uri, err := ipnsURI(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &nameInfo{
+		Name:   peer.ToCid(name).String(),
+		Base36: base36,
+		PeerID: peer.Encode(name),
+		URI:    uri,
+	}
+	if pub, err := name.ExtractPublicKey(); err == nil {
+		info.KeyType = pub.Type().String()
+	}
+	return info, nil
+}
+
+// printNameQR renders content as a QR code on stdout using half block characters, and also writes it to pngPath as a PNG when set
+func printNameQR(content, pngPath string) error {
+	qr, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return err
+	}
+	if pngPath != "" {
+		if err := qr.WriteFile(qrPNGSize, pngPath); err != nil {
+			return err
+		}
+		log.Infow("wrote QR code", "path", pngPath, "content", content)
+	}
+
+	fmt.Print(qr.ToSmallString(false))
+	fmt.Fprintln(os.Stderr, content)
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// maxRateLimitedRetries is how many times a request answered with 429 Too Many Requests is retried before giving up
+const maxRateLimitedRetries = 5
+
+// initialRateLimitBackoff is the first wait after a 429 without a Retry-After header, it doubles on each retry
+const initialRateLimitBackoff = time.Second
+
+// rateLimitedError is returned when a gateway answers 429 Too Many Requests.
+// retryAfter is from the Retry-After header, 0 when the gateway didn't say.
+type rateLimitedError struct {
+	gateway    string
+	retryAfter time.Duration
+}
+
+func (e *rateLimitedError) Error() string {
+	return fmt.Sprintf("%s is rate limiting requests: %s", e.gateway, http.StatusText(http.StatusTooManyRequests))
+}
+
+// parseRetryAfter reads a Retry-After header, which is either a number of seconds or an HTTP date
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if secs, err := strconv.Atoi(header); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// requestLimiter spaces out requests shared between workers so that at most perSecond start each second.
+// It can also be paused, e.g. when a gateway asks everyone to back off. A nil limiter never waits.
+type requestLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// newRequestLimiter returns a limiter for perSecond requests a second, 0 means no limit other than pauses
+func newRequestLimiter(perSecond float64) (*requestLimiter, error) {
+	if perSecond < 0 {
+		return nil, fmt.Errorf("the rate limit cannot be negative, got %v", perSecond)
+	}
+	l := &requestLimiter{}
+	if perSecond > 0 {
+		l.interval = time.Duration(float64(time.Second) / perSecond)
+	}
+	return l, nil
+}
+
+// wait blocks until the caller may start its request. The next slot is re-checked after sleeping, so a pause that
+// happened meanwhile also holds back callers that were already waiting.
+func (l *requestLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		if !l.next.After(now) {
+			l.next = now.Add(l.interval)
+			l.mu.Unlock()
+			return nil
+		}
+		d := l.next.Sub(now)
+		l.mu.Unlock()
+
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// pause stops new requests from starting for d
+func (l *requestLimiter) pause(d time.Duration) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); l.next.Before(until) {
+		l.next = until
+	}
+}
+
+// fetchWithBackoff fetches the record for the name from the gateway once the limiter allows it, giving each attempt
+// timeout. When the gateway answers 429 every request through the limiter is paused, for as long as Retry-After says
+// or else an exponentially growing backoff, and the fetch is retried up to maxRateLimitedRetries times.
+func fetchWithBackoff(ctx context.Context, limiter *requestLimiter, gateway string, name peer.ID, timeout time.Duration) ([]byte, error) {
+	backoff := initialRateLimitBackoff
+	for retry := 0; ; retry++ {
+		if err := limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		data, err := fetchFromGateway(attemptCtx, gateway, name)
+		cancel()
+
+		var limited *rateLimitedError
+		if !errors.As(err, &limited) || retry == maxRateLimitedRetries {
+			return data, err
+		}
+		wait := limited.retryAfter
+		if wait == 0 {
+			wait = backoff
+			backoff *= 2
+		}
+		log.Infow("gateway is rate limiting, backing off", "gateway", gateway, "name", peer.ToCid(name), "wait", wait, "retry", retry+1)
+		limiter.pause(wait)
+	}
+}
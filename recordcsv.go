@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// recordCSVHeader is the header shared by the CSV output of parse records and audit, audit adds its own columns after it
+var recordCSVHeader = []string{"name", "value", "seqno", "eol", "ttl", "valid"}
+
+// recordValidity returns the IPNS name for the record file, from its file name or embedded public key, and whether the
+// record is valid for that name and not expired. The name is empty when it can't be found.
+func recordValidity(file string, data []byte) (string, bool) {
+	rec, err := unmarshalIPNSRecord(data)
+	if err != nil {
+		return "", false
+	}
+	name, err := nameForRecordFile(file, rec)
+	if err != nil {
+		log.Debugw("could not find the IPNS name for the record", "file", file, "error", err)
+		return "", false
+	}
+	if _, err := verifyIPNSRecord(name, rec, false); err != nil {
+		log.Debugw("record is not valid", "file", file, "error", err)
+		return peer.ToCid(name).String(), false
+	}
+	return peer.ToCid(name).String(), true
+}
+
+// writeRecordsCSV writes the records parsed by parse records to stdout as CSV with a header row, one row per record.
+// Records without a TTL have an empty ttl.
+func writeRecordsCSV(results []fileRecord) error {
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write(recordCSVHeader); err != nil {
+		return err
+	}
+	for _, r := range results {
+		name, valid := recordValidity(r.File, r.data)
+		ttl := ""
+		if r.Record.TTL != nil {
+			ttl = *r.Record.TTL
+		}
+		row := []string{name, r.Record.Value, strconv.FormatUint(r.Record.SequenceNumber, 10), r.Record.EOL, ttl, strconv.FormatBool(valid)}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+// redactKeep is how many characters are kept at each end of a redacted field, enough to tell values apart
+const redactKeep = 8
+
+// redactString masks the middle of s, keeping redactKeep characters at each end.
+// Strings too short to keep both ends and still hide something are masked completely.
+func redactString(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	if len(r) <= 3*redactKeep {
+		return "[redacted]"
+	}
+	return string(r[:redactKeep]) + "..." + string(r[len(r)-redactKeep:])
+}
+
+// redact masks the parts of a parsed record that may be sensitive when the output is shared (e.g. pasted into an issue).
+// The sequence number, EOL, and TTL are left as they are since they're usually what's being debugged.
+func (p *parsedRecord) redact() {
+	p.Value = redactString(p.Value)
+	p.Path.Root = redactString(p.Path.Root)
+	p.Path.CID = redactString(p.Path.CID)
+	p.Path.Subpath = redactString(p.Path.Subpath)
+	p.PubKey = redactString(p.PubKey)
+	for k, v := range p.ExtraFields {
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			s = string(v)
+		}
+		p.ExtraFields[k], _ = json.Marshal(redactString(s))
+	}
+}
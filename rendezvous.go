@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+)
+
+// rendezvousVariant is the DHT rendezvous key of a topic under one convention for deriving it
+type rendezvousVariant struct {
+	Convention string
+	UsedBy     string
+	CID        string
+	Multihash  string
+}
+
+// rendezvousConventions are the ways peers have derived the DHT key they advertise and look up pubsub topics under.
+// Since go-ipfs 0.5 the DHT keys provider records by multihash, so variants that only differ in their CID's
+// version or codec find each other, before that the whole CID had to match.
+var rendezvousConventions = []struct {
+	convention, usedBy, prefix string
+	version                    uint64
+	codec                      uint64
+}{
+	{"floodsub: prefix, CIDv1 raw", "go-libp2p-pubsub discovery and the Kubo (go-ipfs 0.5+) pubsub router, see get-dht-key-from-topic", "floodsub:", 1, cid.Raw},
+	{"floodsub: prefix, CIDv0", "older tooling advertising dag-pb CIDs, only matters before go-ipfs 0.5 keyed provider records by multihash", "floodsub:", 0, cid.DagProtobuf},
+	{"no prefix, CIDv1 raw", "go-libp2p routing discovery used directly with the topic as the namespace", "", 1, cid.Raw},
+	{"no prefix, CIDv0", "older tooling hashing the bare topic into a dag-pb CID", "", 0, cid.DagProtobuf},
+}
+
+// getDHTRendezvousVariants returns the rendezvous key of the topic under every known convention
+func getDHTRendezvousVariants(topic string) ([]rendezvousVariant, error) {
+	variants := make([]rendezvousVariant, 0, len(rendezvousConventions))
+	for _, conv := range rendezvousConventions {
+		mh, err := multihash.Sum([]byte(conv.prefix+topic), multihash.SHA2_256, -1)
+		if err != nil {
+			return nil, err
+		}
+
+		c := cid.NewCidV1(conv.codec, mh)
+		if conv.version == 0 {
+			c = cid.NewCidV0(mh)
+		}
+		variants = append(variants, rendezvousVariant{
+			Convention: conv.convention,
+			UsedBy:     conv.usedBy,
+			CID:        c.String(),
+			Multihash:  mh.B58String(),
+		})
+	}
+	return variants, nil
+}
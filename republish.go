@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// republishState is persisted between republishes so a restarted republisher never reuses a sequence number
+type republishState struct {
+	Name           string
+	SequenceNumber uint64
+	Signed         time.Time
+}
+
+// readRepublishState reads the state file, a missing file is an empty state
+func readRepublishState(path string) (*republishState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := &republishState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("could not parse state file %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// writeRepublishState replaces the state file, going through a temporary file so it is never left half written
+func writeRepublishState(path string, state *republishState) error {
+	tmp := path + ".tmp"
+	if err := writeJSONFile(tmp, state); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// republish re-signs the record's value and TTL with the next sequence number and an EOL lifetime from now and publishes
+// it to the gateway every interval, until ctx is done. The first record is published straight away.
+// The sequence number is saved to statePath before each publish, so a crash can skip a number but never reuse one.
+// A failed publish is reported and retried at the next interval rather than stopping the republisher.
+func republish(ctx context.Context, gateway, ipnsKey string, recordBytes []byte, key crypto.PrivKey, statePath string, interval, lifetime time.Duration) error {
+	if interval <= 0 {
+		return errors.New("the republish interval must be positive")
+	}
+	if lifetime <= interval {
+		return fmt.Errorf("the lifetime (%s) must be longer than the republish interval (%s) or the record expires between republishes", lifetime, interval)
+	}
+	if err := checkKeyName(ipnsKey, key); err != nil {
+		return err
+	}
+	name, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	rec, err := unmarshalIPNSRecord(recordBytes)
+	if err != nil {
+		return err
+	}
+	var ttl *time.Duration
+	if rec.Ttl != nil {
+		d := time.Duration(rec.GetTtl())
+		ttl = &d
+	}
+
+	state, err := readRepublishState(statePath)
+	if err != nil {
+		return err
+	}
+	seqno := rec.GetSequence()
+	if state != nil {
+		if state.Name != peer.ToCid(name).String() {
+			return fmt.Errorf("state file %s is for %s, not %s", statePath, state.Name, peer.ToCid(name))
+		}
+		if state.SequenceNumber > seqno {
+			seqno = state.SequenceNumber
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		seqno++
+		now := time.Now()
+		if err := writeRepublishState(statePath, &republishState{Name: peer.ToCid(name).String(), SequenceNumber: seqno, Signed: now}); err != nil {
+			return err
+		}
+
+		signed, err := signIPNSRecord(int64(seqno), ttl, now.Add(lifetime), string(rec.GetValue()), nil, key)
+		if err != nil {
+			return err
+		}
+		log.Infow("republishing record", "name", peer.ToCid(name), "seqno", seqno, "eol", now.Add(lifetime))
+		if err := publishToGateway(ctx, gateway, ipnsKey, signed, false); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			fmt.Fprintf(os.Stderr, "warning: republishing sequence number %d failed, retrying in %s: %v\n", seqno, interval, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// resolveStep is one IPNS record followed while resolving a name.
+// Gateway is the gateway that answered when several were queried.
+type resolveStep struct {
+	Name           string
+	Value          string
+	SequenceNumber uint64
+	Gateway        string `json:",omitempty"`
+}
+
+// resolution is the content path a name resolved to and the records followed to get there.
+// record is the raw record for the name that was resolved, i.e. the first in the chain, as the gateway served it.
+type resolution struct {
+	Path   string
+	Chain  []resolveStep
+	record []byte
+}
+
+// resolveName fetches and verifies the record for the name from the gateway. With several gateways they are queried
+// concurrently for each record and the first valid, unexpired answer is used, see fetchFirstValid.
+// When recursive, values pointing at other IPNS names are followed the same way, up to maxDepth records,
+// and subpaths are carried over (e.g. /ipns/a -> /ipns/b/x, b -> /ipfs/c/y resolves to /ipfs/c/y/x).
+// A chain that comes back to a name it has already visited is an error. The resolution is never nil, on an error it
+// has the records followed before it.
+func resolveName(ctx context.Context, gateways []string, name peer.ID, recursive bool, maxDepth int) (*resolution, error) {
+	res := &resolution{}
+	if len(gateways) == 0 {
+		return res, errors.New("no gateway to resolve with")
+	}
+	if maxDepth < 1 {
+		return res, fmt.Errorf("max depth must be at least 1, got %d", maxDepth)
+	}
+
+	visited := map[peer.ID]bool{}
+	var rest string
+	for {
+		if visited[name] {
+			return res, fmt.Errorf("cycle detected: %s was already resolved in this chain", peer.ToCid(name))
+		}
+		if len(res.Chain) == maxDepth {
+			return res, fmt.Errorf("gave up after following %d records, raise --max-depth to follow longer chains", maxDepth)
+		}
+		visited[name] = true
+
+		var rec *ipns_pb.IpnsEntry
+		var recBytes []byte
+		var answered string
+		var err error
+		if len(gateways) == 1 {
+			rec, recBytes, err = fetchVerified(ctx, gateways[0], name)
+		} else {
+			rec, recBytes, answered, err = fetchFirstValid(ctx, gateways, name)
+		}
+		if err != nil {
+			return res, err
+		}
+		if res.record == nil {
+			res.record = recBytes
+		}
+
+		value := string(rec.Value)
+		log.Infow("resolved name", "name", peer.ToCid(name), "value", value)
+		res.Chain = append(res.Chain, resolveStep{Name: peer.ToCid(name).String(), Value: value, SequenceNumber: rec.GetSequence(), Gateway: answered})
+		res.Path = value + rest
+
+		if !recursive || !strings.HasPrefix(value, "/ipns/") {
+			return res, nil
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(value, "/ipns/"), "/", 2)
+		next, err := decodeIPNSName(parts[0])
+		if err != nil {
+			return res, fmt.Errorf("%s points at %s which is not an IPNS name, it may be a DNSLink domain: %w", peer.ToCid(name), value, err)
+		}
+		if len(parts) == 2 {
+			rest = "/" + parts[1] + rest
+		}
+		name = next
+	}
+}
+
+// fetchVerified fetches the record for the name from the gateway and checks it is valid and unexpired.
+// It returns the parsed record along with the bytes the gateway served.
+func fetchVerified(ctx context.Context, gateway string, name peer.ID) (*ipns_pb.IpnsEntry, []byte, error) {
+	recBytes, err := fetchFromGateway(ctx, gateway, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	rec, err := unmarshalIPNSRecord(recBytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not unmarshal the record for %s: %w", peer.ToCid(name), err)
+	}
+	if _, err := verifyIPNSRecord(name, rec, false); err != nil {
+		return nil, nil, fmt.Errorf("the record for %s is not valid: %w", peer.ToCid(name), err)
+	}
+	return rec, recBytes, nil
+}
+
+// gatewayAnswer is the outcome of fetching a record from one of several gateways
+type gatewayAnswer struct {
+	gateway  string
+	rec      *ipns_pb.IpnsEntry
+	recBytes []byte
+	err      error
+}
+
+// fetchFirstValid queries the gateways concurrently and returns the first valid, unexpired record for the name and the
+// gateway that served it, cancelling the requests still in flight. It fails with every gateway's error when none has one.
+func fetchFirstValid(ctx context.Context, gateways []string, name peer.ID) (*ipns_pb.IpnsEntry, []byte, string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	answers := make(chan gatewayAnswer, len(gateways))
+	for _, gateway := range gateways {
+		go func(gateway string) {
+			rec, recBytes, err := fetchVerified(ctx, gateway, name)
+			answers <- gatewayAnswer{gateway: gateway, rec: rec, recBytes: recBytes, err: err}
+		}(gateway)
+	}
+
+	failures := make([]string, 0, len(gateways))
+	for range gateways {
+		a := <-answers
+		if a.err == nil {
+			log.Infow("gateway answered first", "name", peer.ToCid(name), "gateway", a.gateway)
+			return a.rec, a.recBytes, a.gateway, nil
+		}
+		log.Debugw("gateway failed", "name", peer.ToCid(name), "gateway", a.gateway, "error", a.err)
+		failures = append(failures, fmt.Sprintf("%s: %v", a.gateway, a.err))
+	}
+	return nil, nil, "", fmt.Errorf("no gateway returned a valid record for %s: %s", peer.ToCid(name), strings.Join(failures, "; "))
+}
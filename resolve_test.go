@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+)
+
+func TestResolveNameErrorsReturnResolution(t *testing.T) {
+	priv, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := mustPeerID(t, priv)
+
+	tests := []struct {
+		name     string
+		gateways []string
+		maxDepth int
+	}{
+		{"no gateways", nil, 1},
+		{"max depth below 1", []string{"http://127.0.0.1:1"}, 0},
+		{"unreachable gateway", []string{"http://127.0.0.1:1"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := resolveName(context.Background(), tt.gateways, name, false, tt.maxDepth)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			// callers print the partial chain on errors, so the resolution is never nil
+			if res == nil {
+				t.Fatal("got a nil resolution")
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// jsonSchemaDraft is the JSON Schema version of the generated schemas
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+var (
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	timeType       = reflect.TypeOf(time.Time{})
+)
+
+// outputSchemas are the JSON outputs a schema can be printed for, keyed by the command that outputs them
+var outputSchemas = map[string]interface{}{
+	"parse-record":  parsedRecord{},
+	"parse-records": []fileRecord{},
+	"parse-key":     parsedKey{},
+}
+
+// printSchema prints the JSON Schema of the output of the command.
+// The schema is generated from the output types so it stays in sync with them.
+func printSchema(command string, compact bool) error {
+	v, ok := outputSchemas[command]
+	if !ok {
+		return fmt.Errorf("no schema for %q", command)
+	}
+
+	schema := jsonSchema(reflect.TypeOf(v))
+	schema["$schema"] = jsonSchemaDraft
+	schema["title"] = "ipns-utils " + strings.Replace(command, "-", " ", 1) + " output"
+	return printJSON(schema, compact)
+}
+
+// jsonSchema returns the schema of values of type t as encoding/json marshals them
+func jsonSchema(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == rawMessageType:
+		return map[string]interface{}{}
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := jsonSchema(t.Elem())
+		if typ, ok := s["type"].(string); ok {
+			s["type"] = []string{typ, "null"}
+		}
+		return s
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchema(t.Elem())}
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name, opts := f.Name, ""
+			if tag, ok := f.Tag.Lookup("json"); ok {
+				if tag == "-" {
+					continue
+				}
+				parts := strings.SplitN(tag, ",", 2)
+				if parts[0] != "" {
+					name = parts[0]
+				}
+				if len(parts) == 2 {
+					opts = parts[1]
+				}
+			}
+			props[name] = jsonSchema(f.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           props,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	default:
+		return map[string]interface{}{}
+	}
+}
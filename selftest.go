@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/multiformats/go-multibase"
+)
+
+// selftestSizes are the lengths of random data round tripped, covering the padding cases of every base.
+// Empty data is left out, nothing ipns-utils encodes is empty and base36 and base58 can't decode it.
+var selftestSizes = []int{1, 2, 3, 4, 5, 31, 32, 33, 255, 1024}
+
+// selftestMultibase encodes random data, a record, and a key in every multibase the library supports the same way
+// --output-base does and decodes them back, failing if any of them come back different.
+func selftestMultibase() error {
+	inputs := make(map[string][]byte)
+	for _, n := range selftestSizes {
+		data := make([]byte, n)
+		if _, err := rand.Read(data); err != nil {
+			return err
+		}
+		inputs[fmt.Sprintf("%d random bytes", n)] = data
+	}
+	// base36 and base58 encode leading zero bytes separately
+	inputs["leading zero bytes"] = []byte{0, 0, 0, 1, 2, 3}
+
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		return err
+	}
+	if inputs["key"], err = crypto.MarshalPrivateKey(priv); err != nil {
+		return err
+	}
+	if inputs["record"], err = signIPNSRecord(1, nil, time.Now().Add(time.Hour), "/ipfs/bafkqaaa", nil, priv); err != nil {
+		return err
+	}
+
+	var bases []string
+	for _, name := range multibase.EncodingToStr {
+		bases = append(bases, name)
+	}
+	sort.Strings(bases)
+
+	var failures int
+	for _, base := range bases {
+		var errs []string
+		for label, data := range inputs {
+			if err := roundTripBase(data, base); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", label, err))
+			}
+		}
+		if len(errs) == 0 {
+			fmt.Printf("%s: ok\n", base)
+			continue
+		}
+		failures++
+		sort.Strings(errs)
+		fmt.Printf("%s: FAILED\n", base)
+		for _, e := range errs {
+			fmt.Printf("  %s\n", e)
+		}
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d of %d multibases did not round trip", failures, len(bases))
+	}
+	return nil
+}
+
+// roundTripBase writes data with writeEncoded and checks that decoding the output gives back the same bytes
+func roundTripBase(data []byte, base string) error {
+	var buf bytes.Buffer
+	if err := writeEncoded(&buf, data, base); err != nil {
+		return err
+	}
+
+	out := buf.String()
+	if base != "identity" {
+		if !strings.HasSuffix(out, "\n") {
+			return fmt.Errorf("text output has no trailing newline")
+		}
+		out = strings.TrimSuffix(out, "\n")
+	}
+	enc, decoded, err := multibase.Decode(out)
+	if err != nil {
+		return fmt.Errorf("could not decode: %w", err)
+	}
+	if name := multibase.EncodingToStr[enc]; name != base {
+		return fmt.Errorf("decoded as %s", name)
+	}
+	if !bytes.Equal(decoded, data) {
+		return fmt.Errorf("decoded bytes differ")
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multibase"
+)
+
+// maxShares is the most shares GF(256) allows, each share needs a distinct non-zero x coordinate
+const maxShares = 255
+
+// gfExp and gfLog are exponent and logarithm tables for GF(256) with the AES polynomial x^8 + x^4 + x^3 + x + 1 and generator 3
+var gfExp, gfLog [256]byte
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfLog[x] = byte(i)
+		// multiply by the generator 3, i.e. x*2 + x
+		hi := x & 0x80
+		x2 := x << 1
+		if hi != 0 {
+			x2 ^= 0x1b
+		}
+		x ^= x2
+	}
+	gfExp[255] = gfExp[0]
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])+int(gfLog[b]))%255]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])+255-int(gfLog[b]))%255]
+}
+
+// splitSecret splits the secret into n shares, any threshold of which reconstruct it with combineShares.
+// Each byte of the secret is the constant term of its own random polynomial of degree threshold-1, share i holds
+// the polynomials evaluated at x = i+1.
+func splitSecret(secret []byte, n, threshold int) ([][]byte, error) {
+	if threshold < 2 {
+		return nil, fmt.Errorf("the threshold must be at least 2, got %d", threshold)
+	}
+	if n < threshold {
+		return nil, fmt.Errorf("cannot make %d shares with a threshold of %d, there must be at least as many shares as the threshold", n, threshold)
+	}
+	if n > maxShares {
+		return nil, fmt.Errorf("at most %d shares are supported, got %d", maxShares, n)
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("cannot split an empty secret")
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret))
+	}
+	coeffs := make([]byte, threshold)
+	for b, s := range secret {
+		coeffs[0] = s
+		if _, err := rand.Read(coeffs[1:]); err != nil {
+			return nil, err
+		}
+		for i := range shares {
+			// Horner's method at x = i+1
+			x, y := byte(i+1), byte(0)
+			for j := threshold - 1; j >= 0; j-- {
+				y = gfMul(y, x) ^ coeffs[j]
+			}
+			shares[i][b] = y
+		}
+	}
+	return shares, nil
+}
+
+// combineShares reconstructs the secret from shares keyed by their x coordinate with Lagrange interpolation at x = 0.
+// Given fewer shares than the threshold it returns garbage rather than an error, callers must check the result.
+func combineShares(shares map[byte][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, fmt.Errorf("at least 2 shares are needed, got %d", len(shares))
+	}
+	var size int
+	for x, y := range shares {
+		if x == 0 {
+			return nil, errors.New("share index 0 is not valid")
+		}
+		if size == 0 {
+			size = len(y)
+		} else if len(y) != size {
+			return nil, errors.New("the shares have different lengths, they are not from the same split")
+		}
+	}
+
+	secret := make([]byte, size)
+	for xi, yi := range shares {
+		// the Lagrange basis polynomial for xi at 0 is the product of xj / (xj - xi), subtraction is xor in GF(256)
+		basis := byte(1)
+		for xj := range shares {
+			if xj != xi {
+				basis = gfMul(basis, gfDiv(xj, xj^xi))
+			}
+		}
+		for b := range secret {
+			secret[b] ^= gfMul(basis, yi[b])
+		}
+	}
+	return secret, nil
+}
+
+// keyShare is a share file written by create id --shares.
+// Share is the multibase encoded share of the marshalled private key, Index is its x coordinate.
+type keyShare struct {
+	Name      string
+	Threshold int
+	Shares    int
+	Index     int
+	Share     string
+}
+
+// writeKeyShares splits the private key into n shares, any threshold of which reconstruct it, and writes each to
+// <dir>/<name>.share-<index>-of-<n>.json with mode 0600. It returns the paths written.
+func writeKeyShares(priv crypto.PrivKey, n, threshold int, dir string) ([]string, error) {
+	keyBytes, err := crypto.MarshalPrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	pid, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	name := peer.ToCid(pid).String()
+
+	shares, err := splitSecret(keyBytes, n, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, n)
+	for i, share := range shares {
+		encoded, err := multibase.Encode(multibase.Base64, share)
+		if err != nil {
+			return nil, err
+		}
+		out, err := json.MarshalIndent(keyShare{Name: name, Threshold: threshold, Shares: n, Index: i + 1, Share: encoded}, "", "    ")
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%s.share-%d-of-%d.json", name, i+1, n))
+		if err := os.WriteFile(path, append(out, '\n'), 0600); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// reconstructKey rebuilds the private key from share files written by writeKeyShares.
+// The shares must be for the same name and split, and the rebuilt key must be the key for that name.
+func reconstructKey(paths []string) (crypto.PrivKey, error) {
+	var first *keyShare
+	shares := make(map[byte][]byte, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		s := &keyShare{}
+		if err := json.Unmarshal(data, s); err != nil {
+			return nil, fmt.Errorf("%s is not a key share: %w", path, err)
+		}
+		if s.Index < 1 || s.Index > maxShares {
+			return nil, fmt.Errorf("%s has share index %d, it must be between 1 and %d", path, s.Index, maxShares)
+		}
+
+		if first == nil {
+			first = s
+		} else if s.Name != first.Name || s.Threshold != first.Threshold || s.Shares != first.Shares {
+			return nil, fmt.Errorf("%s is a share of %s (%d of %d), not of %s (%d of %d) like the other shares", path, s.Name, s.Threshold, s.Shares, first.Name, first.Threshold, first.Shares)
+		}
+		if _, ok := shares[byte(s.Index)]; ok {
+			return nil, fmt.Errorf("%s is share %d, which was already given", path, s.Index)
+		}
+
+		_, share, err := multibase.Decode(s.Share)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode the share in %s: %w", path, err)
+		}
+		shares[byte(s.Index)] = share
+	}
+	if first == nil {
+		return nil, errors.New("no shares given, pass them with --share")
+	}
+	if len(shares) < first.Threshold {
+		return nil, fmt.Errorf("%d shares were given but %d are needed to reconstruct the key for %s", len(shares), first.Threshold, first.Name)
+	}
+
+	keyBytes, err := combineShares(shares)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := crypto.UnmarshalPrivateKey(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("the shares did not reconstruct a valid key, some are likely corrupt: %w", err)
+	}
+	if err := checkKeyName(first.Name, priv); err != nil {
+		return nil, fmt.Errorf("the shares did not reconstruct the key for %s, some are likely corrupt: %w", first.Name, err)
+	}
+	return priv, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestGF256(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			if got := gfDiv(gfMul(byte(a), byte(b)), byte(b)); got != byte(a) {
+				t.Fatalf("%d * %d / %d = %d", a, b, b, got)
+			}
+		}
+	}
+	// x * 2 in GF(256) is a shift, reduced by the AES polynomial on overflow
+	if got := gfMul(0x80, 2); got != 0x1b {
+		t.Errorf("0x80 * 2 = %#x, want 0x1b", got)
+	}
+}
+
+func TestSplitCombineSecret(t *testing.T) {
+	secret := []byte("a secret that is longer than one byte \x00\xff")
+	tests := []struct {
+		n, threshold int
+	}{
+		{2, 2},
+		{3, 2},
+		{5, 3},
+		{255, 2},
+	}
+	for _, tt := range tests {
+		shares, err := splitSecret(secret, tt.n, tt.threshold)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(shares) != tt.n {
+			t.Fatalf("got %d shares, want %d", len(shares), tt.n)
+		}
+
+		// every window of threshold consecutive shares reconstructs the secret
+		for start := 0; start+tt.threshold <= tt.n; start++ {
+			subset := make(map[byte][]byte)
+			for i := start; i < start+tt.threshold; i++ {
+				subset[byte(i+1)] = shares[i]
+			}
+			got, err := combineShares(subset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, secret) {
+				t.Fatalf("%d of %d shares from %d reconstructed %q", tt.threshold, tt.n, start+1, got)
+			}
+		}
+	}
+}
+
+func TestSplitSecretFewerSharesThanThreshold(t *testing.T) {
+	secret := bytes.Repeat([]byte{0x42}, 32)
+	shares, err := splitSecret(secret, 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := combineShares(map[byte][]byte{1: shares[0], 2: shares[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(got, secret) {
+		t.Fatal("2 shares of a threshold of 3 reconstructed the secret")
+	}
+}
+
+func TestSplitSecretErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		secret       []byte
+		n, threshold int
+	}{
+		{"threshold below 2", []byte("x"), 3, 1},
+		{"fewer shares than the threshold", []byte("x"), 2, 3},
+		{"too many shares", []byte("x"), 256, 2},
+		{"empty secret", nil, 3, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := splitSecret(tt.secret, tt.n, tt.threshold); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestCombineSharesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		shares map[byte][]byte
+	}{
+		{"one share", map[byte][]byte{1: {1}}},
+		{"index 0", map[byte][]byte{0: {1}, 1: {2}}},
+		{"different lengths", map[byte][]byte{1: {1}, 2: {1, 2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := combineShares(tt.shares); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestKeySharesRoundTrip(t *testing.T) {
+	priv, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	paths, err := writeKeyShares(priv, 5, 3, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 5 {
+		t.Fatalf("got %d share files, want 5", len(paths))
+	}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("%s has mode %v, want 0600", p, info.Mode().Perm())
+		}
+	}
+
+	got, err := reconstructKey([]string{paths[4], paths[0], paths[2]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equals(priv) {
+		t.Fatal("the reconstructed key is not the original key")
+	}
+
+	if _, err := reconstructKey(paths[:2]); err == nil {
+		t.Fatal("expected an error with fewer shares than the threshold")
+	}
+	if _, err := reconstructKey([]string{paths[0], paths[0], paths[1]}); err == nil {
+		t.Fatal("expected an error for a repeated share")
+	}
+}
+
+func TestReconstructKeyRejectsMixedShares(t *testing.T) {
+	dir := t.TempDir()
+	var all [][]string
+	for i := 0; i < 2; i++ {
+		priv, _, err := crypto.GenerateEd25519Key(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub := filepath.Join(dir, peer.ToCid(mustPeerID(t, priv)).String())
+		if err := os.Mkdir(sub, 0o700); err != nil {
+			t.Fatal(err)
+		}
+		paths, err := writeKeyShares(priv, 2, 2, sub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, paths)
+	}
+	if _, err := reconstructKey([]string{all[0][0], all[1][1]}); err == nil {
+		t.Fatal("expected an error for shares of different keys")
+	}
+}
+
+func mustPeerID(t *testing.T, priv crypto.PrivKey) peer.ID {
+	t.Helper()
+	id, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
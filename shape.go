@@ -0,0 +1,51 @@
+package main
+
+import (
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+)
+
+// recordShape is which optional protobuf fields a record has, which hints at the library that produced it
+type recordShape struct {
+	Fields     []string
+	Signatures string
+	Producer   string
+}
+
+// newRecordShape returns the optional fields present in the record.
+// go-ipns added SignatureV2 and the CBOR Data in v0.1.0, before that records only had the V1 signature,
+// and newer implementations may leave out the V1 signature entirely.
+func newRecordShape(rec *ipns_pb.IpnsEntry) recordShape {
+	present := []struct {
+		name string
+		ok   bool
+	}{
+		{"SignatureV1", len(rec.SignatureV1) > 0},
+		{"SignatureV2", len(rec.SignatureV2) > 0},
+		{"Data", len(rec.Data) > 0},
+		{"Ttl", rec.Ttl != nil},
+		{"PubKey", len(rec.PubKey) > 0},
+	}
+	shape := recordShape{Fields: []string{}}
+	for _, f := range present {
+		if f.ok {
+			shape.Fields = append(shape.Fields, f.name)
+		}
+	}
+
+	v1, v2 := len(rec.SignatureV1) > 0, len(rec.SignatureV2) > 0 && len(rec.Data) > 0
+	switch {
+	case v1 && v2:
+		shape.Signatures = "v1+v2"
+		shape.Producer = "go-ipns v0.1.0 or later, or another implementation writing both signatures"
+	case v2:
+		shape.Signatures = "v2"
+		shape.Producer = "an implementation that only writes V2 signatures, older V1-only resolvers will reject it"
+	case v1:
+		shape.Signatures = "v1"
+		shape.Producer = "go-ipns before v0.1.0, or another implementation without V2 signatures"
+	default:
+		shape.Signatures = "none"
+		shape.Producer = "unknown, the record is unsigned"
+	}
+	return shape
+}
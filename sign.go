@@ -0,0 +1,165 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/ipfs/go-ipns"
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+	"github.com/libp2p/go-libp2p-core/crypto"
+
+	"github.com/urfave/cli/v2"
+)
+
+// signRecoverable signs the sha256 digest of data, as libp2p does for secp256k1 keys, and returns the
+// 65 byte R || S || V signature where V is the recovery ID (0 or 1) used to recover the public key.
+func signRecoverable(priv crypto.PrivKey, data []byte) ([]byte, error) {
+	k, ok := priv.(*crypto.Secp256k1PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("recoverable signatures are only supported for secp256k1 keys, not %s", priv.Type())
+	}
+
+	hash := sha256.Sum256(data)
+	compact, err := btcec.SignCompact(btcec.S256(), (*btcec.PrivateKey)(k), hash[:], true)
+	if err != nil {
+		return nil, err
+	}
+
+	// btcec puts a header of 27 + recovery ID + 4 (for compressed keys) first, move it to the end as just the recovery ID
+	sig := append(compact[1:], compact[0]-27-4)
+	return sig, nil
+}
+
+// signData signs data with the key and writes the signature to stdout, encoded with outputBase if set
+func signData(priv crypto.PrivKey, data []byte, recoverable bool, outputBase string) error {
+	var sig []byte
+	var err error
+	if recoverable {
+		sig, err = signRecoverable(priv, data)
+	} else {
+		sig, err = priv.Sign(data)
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(sig, outputBase)
+}
+
+// signPartialRecord fills in the CBOR data, signatures, and, if needed, public key of a record whose fields were built elsewhere.
+// The fields are used exactly as they are, e.g. the validity is not reformatted.
+func signPartialRecord(rec *ipns_pb.IpnsEntry, priv crypto.PrivKey) ([]byte, error) {
+	var missing []string
+	if rec.Value == nil {
+		missing = append(missing, "value")
+	}
+	if rec.Validity == nil {
+		missing = append(missing, "validity")
+	}
+	if rec.ValidityType == nil {
+		missing = append(missing, "validity type")
+	}
+	if rec.Sequence == nil {
+		missing = append(missing, "sequence number")
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("cannot sign the record, it is missing: %s", strings.Join(missing, ", "))
+	}
+
+	data, err := standardCBORData(rec)
+	if err != nil {
+		return nil, err
+	}
+	rec.Data = data
+
+	v1, v2 := recordSigningBytes(rec)
+	if rec.SignatureV1, err = priv.Sign(v1); err != nil {
+		return nil, err
+	}
+	if rec.SignatureV2, err = priv.Sign(v2); err != nil {
+		return nil, err
+	}
+
+	rec.PubKey = nil
+	if err := ipns.EmbedPublicKey(priv.GetPublic(), rec); err != nil {
+		return nil, err
+	}
+	return rec.Marshal()
+}
+
+// signOnlyConflicts are the create record flags that can't be used with --sign-only, every flag that builds, checks,
+// or changes the record other than the fields signOnly takes
+var signOnlyConflicts = []string{
+	"eol", "lifetime", "ttl-from-eol", "base-record", "extra-field", "interactive", "from-add",
+	"corrupt", "validity-type", "minimal", "maximal",
+	"pkcs11-module", "pkcs11-label", "pkcs11-pin-env",
+	"warn-short-lifetime", "strict", "check-value", "gateway", "check-timeout",
+	"stats", "stats-file",
+}
+
+// signOnly is create record --sign-only, it takes the record fields from --partial-record and the flags and signs them as they are
+func signOnly(c *cli.Context) error {
+	for _, f := range signOnlyConflicts {
+		if c.IsSet(f) {
+			return fmt.Errorf("cannot use --%s with --sign-only, the record fields are signed as given", f)
+		}
+	}
+	if c.Bool("http-response") && c.String("output-base") != "" {
+		return errors.New("cannot use an output base with --http-response")
+	}
+
+	rec := &ipns_pb.IpnsEntry{}
+	if partial := c.Path("partial-record"); partial != "" {
+		var err error
+		if rec, err = readIPNSRecordFile(partial, "auto"); err != nil {
+			return err
+		}
+	}
+	if c.IsSet("value") {
+		rec.Value = []byte(c.String("value"))
+	}
+	if c.IsSet("validity") {
+		rec.Validity = []byte(c.String("validity"))
+		if rec.ValidityType == nil {
+			typ := ipns_pb.IpnsEntry_EOL
+			rec.ValidityType = &typ
+		}
+	}
+	if c.IsSet("seqno") {
+		seqno := uint64(c.Int64("seqno"))
+		rec.Sequence = &seqno
+	}
+	var ttl *time.Duration
+	if c.IsSet("ttl") {
+		ttl = &c.Generic("ttl").(*durationValue).d
+		ns := uint64(ttl.Nanoseconds())
+		rec.Ttl = &ns
+	} else if rec.Ttl != nil {
+		d := time.Duration(rec.GetTtl())
+		ttl = &d
+	}
+
+	keyEncoded, err := encodedKey(c)
+	if err != nil {
+		return err
+	}
+	key, err := loadPrivateKey(c.Path("key-file"), keyEncoded, keyPassword(c))
+	if err != nil {
+		return err
+	}
+	if c.IsSet("name") {
+		if err := checkKeyName(c.String("name"), key); err != nil {
+			return err
+		}
+	}
+
+	recBytes, err := signPartialRecord(rec, key)
+	if err != nil {
+		return err
+	}
+	return outputRecord(recBytes, ttl, c.String("output-base"), c.Bool("http-response"))
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/sha256"
+	"flag"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/ipfs/go-ipns"
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+	"github.com/libp2p/go-libp2p-core/crypto"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestSignRecoverable(t *testing.T) {
+	priv, _, err := crypto.GenerateSecp256k1Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("data to sign")
+	sig, err := signRecoverable(priv, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sig) != 65 {
+		t.Fatalf("got a %d byte signature, want 65", len(sig))
+	}
+	if v := sig[64]; v > 1 {
+		t.Fatalf("got recovery ID %d, want 0 or 1", v)
+	}
+
+	// R || S is a normal libp2p signature
+	r, s := sig[:32], sig[32:64]
+	der := (&btcec.Signature{R: new(big.Int).SetBytes(r), S: new(big.Int).SetBytes(s)}).Serialize()
+	ok, err := priv.GetPublic().Verify(data, der)
+	if err != nil || !ok {
+		t.Fatalf("R || S doesn't verify with the public key: %v", err)
+	}
+
+	// btcec expects the header 27 + recovery ID + 4 for compressed keys in front
+	hash := sha256.Sum256(data)
+	compact := append([]byte{27 + 4 + sig[64]}, sig[:64]...)
+	recovered, _, err := btcec.RecoverCompact(btcec.S256(), compact, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := crypto.UnmarshalSecp256k1PublicKey(recovered.SerializeCompressed())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pub.Equals(priv.GetPublic()) {
+		t.Fatal("the recovered public key is not the signer's")
+	}
+}
+
+func TestSignRecoverableOtherKeyTypes(t *testing.T) {
+	priv, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signRecoverable(priv, []byte("data")); err == nil {
+		t.Fatal("expected an error for an Ed25519 key")
+	}
+}
+
+func TestSignPartialRecord(t *testing.T) {
+	priv, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seqno := uint64(5)
+	typ := ipns_pb.IpnsEntry_EOL
+	validity := time.Now().Add(time.Hour).UTC().Format(time.RFC3339Nano)
+	rec := &ipns_pb.IpnsEntry{Value: []byte("/ipfs/bafkqaaa"), Validity: []byte(validity), ValidityType: &typ, Sequence: &seqno}
+
+	recBytes, err := signPartialRecord(rec, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := unmarshalIPNSRecord(recBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ipns.Validate(priv.GetPublic(), signed); err != nil {
+		t.Fatal(err)
+	}
+	if string(signed.Validity) != validity {
+		t.Fatalf("the validity was changed to %q", signed.Validity)
+	}
+
+	if _, err := signPartialRecord(&ipns_pb.IpnsEntry{Value: []byte("/ipfs/bafkqaaa")}, priv); err == nil {
+		t.Fatal("expected an error for a record missing fields")
+	}
+}
+
+func TestSignOnlyRejectsUnusedFlags(t *testing.T) {
+	rejected := []string{
+		"eol", "lifetime", "ttl-from-eol", "base-record", "extra-field", "interactive", "from-add",
+		"corrupt", "validity-type", "minimal", "maximal", "pkcs11-module", "pkcs11-label", "pkcs11-pin-env",
+		"warn-short-lifetime", "strict", "check-value", "gateway", "check-timeout", "stats", "stats-file",
+	}
+	for _, f := range rejected {
+		t.Run(f, func(t *testing.T) {
+			set := flag.NewFlagSet("record", flag.ContinueOnError)
+			set.String(f, "", "")
+			if err := set.Parse([]string{"--" + f + "=x"}); err != nil {
+				t.Fatal(err)
+			}
+			err := signOnly(cli.NewContext(nil, set, nil))
+			if err == nil || !strings.Contains(err.Error(), "--"+f+" ") {
+				t.Fatalf("got error %v, want one for --%s", err, f)
+			}
+		})
+	}
+
+	set := flag.NewFlagSet("record", flag.ContinueOnError)
+	set.Bool("http-response", false, "")
+	set.String("output-base", "", "")
+	if err := set.Parse([]string{"--http-response", "--output-base=base36"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := signOnly(cli.NewContext(nil, set, nil)); err == nil || !strings.Contains(err.Error(), "--http-response") {
+		t.Fatalf("got error %v for --http-response with an output base", err)
+	}
+}
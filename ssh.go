@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"golang.org/x/crypto/ssh"
+)
+
+const opensshKeyMagic = "openssh-key-v1\x00"
+
+// opensshKey is the unencrypted openssh-key-v1 container described in OpenSSH's PROTOCOL.key
+type opensshKey struct {
+	CipherName   string
+	KdfName      string
+	KdfOpts      string
+	NumKeys      uint32
+	PubKey       []byte
+	PrivKeyBlock []byte
+}
+
+type opensshEd25519Key struct {
+	Check1  uint32
+	Check2  uint32
+	KeyType string
+	Pub     []byte
+	Priv    []byte
+	Comment string
+	Pad     []byte `ssh:"rest"`
+}
+
+type opensshRSAKey struct {
+	Check1  uint32
+	Check2  uint32
+	KeyType string
+	N       *big.Int
+	E       *big.Int
+	D       *big.Int
+	Iqmp    *big.Int
+	P       *big.Int
+	Q       *big.Int
+	Comment string
+	Pad     []byte `ssh:"rest"`
+}
+
+type opensshECDSAKey struct {
+	Check1  uint32
+	Check2  uint32
+	KeyType string
+	Curve   string
+	Pub     []byte
+	D       *big.Int
+	Comment string
+	Pad     []byte `ssh:"rest"`
+}
+
+// toOpenSSHKey converts a libp2p private key to an OpenSSH private key (PEM) and authorized_keys line.
+// Only Ed25519, RSA, and ECDSA keys have an SSH representation.
+func toOpenSSHKey(priv crypto.PrivKey, comment string) ([]byte, []byte, error) {
+	if priv.Type() == crypto.Secp256k1 {
+		return nil, nil, fmt.Errorf("%s keys have no SSH representation", priv.Type())
+	}
+
+	stdKey, err := crypto.PrivKeyToStdKey(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var checkBytes [4]byte
+	if _, err := rand.Read(checkBytes[:]); err != nil {
+		return nil, nil, err
+	}
+	check := binary.BigEndian.Uint32(checkBytes[:])
+
+	var sshPub ssh.PublicKey
+	var privBlock []byte
+	switch k := stdKey.(type) {
+	case *ed25519.PrivateKey:
+		pub := k.Public().(ed25519.PublicKey)
+		if sshPub, err = ssh.NewPublicKey(pub); err != nil {
+			return nil, nil, err
+		}
+		privBlock = ssh.Marshal(opensshEd25519Key{
+			Check1:  check,
+			Check2:  check,
+			KeyType: ssh.KeyAlgoED25519,
+			Pub:     pub,
+			Priv:    *k,
+			Comment: comment,
+		})
+	case *rsa.PrivateKey:
+		if sshPub, err = ssh.NewPublicKey(&k.PublicKey); err != nil {
+			return nil, nil, err
+		}
+		k.Precompute()
+		privBlock = ssh.Marshal(opensshRSAKey{
+			Check1:  check,
+			Check2:  check,
+			KeyType: ssh.KeyAlgoRSA,
+			N:       k.N,
+			E:       big.NewInt(int64(k.E)),
+			D:       k.D,
+			Iqmp:    k.Precomputed.Qinv,
+			P:       k.Primes[0],
+			Q:       k.Primes[1],
+			Comment: comment,
+		})
+	case *ecdsa.PrivateKey:
+		if sshPub, err = ssh.NewPublicKey(&k.PublicKey); err != nil {
+			return nil, nil, err
+		}
+		curve := "nistp" + fmt.Sprint(k.Curve.Params().BitSize)
+		privBlock = ssh.Marshal(opensshECDSAKey{
+			Check1:  check,
+			Check2:  check,
+			KeyType: sshPub.Type(),
+			Curve:   curve,
+			Pub:     elliptic.Marshal(k.Curve, k.X, k.Y),
+			D:       k.D,
+			Comment: comment,
+		})
+	default:
+		return nil, nil, fmt.Errorf("%s keys have no SSH representation", priv.Type())
+	}
+
+	// The private block is padded to the cipher block size, 8 for the none cipher
+	for i := byte(1); len(privBlock)%8 != 0; i++ {
+		privBlock = append(privBlock, i)
+	}
+
+	container := ssh.Marshal(opensshKey{
+		CipherName:   "none",
+		KdfName:      "none",
+		NumKeys:      1,
+		PubKey:       sshPub.Marshal(),
+		PrivKeyBlock: privBlock,
+	})
+
+	privPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "OPENSSH PRIVATE KEY",
+		Bytes: append([]byte(opensshKeyMagic), container...),
+	})
+
+	authorizedKey := ssh.MarshalAuthorizedKey(sshPub)
+	if comment != "" {
+		authorizedKey = append(authorizedKey[:len(authorizedKey)-1], []byte(" "+comment+"\n")...)
+	}
+	return privPEM, authorizedKey, nil
+}
+
+// convertKeyToOpenSSH writes the OpenSSH private key to out and the public key to out.pub.
+// Without out the private key goes to stdout and the public key to stderr.
+func convertKeyToOpenSSH(priv crypto.PrivKey, out string) error {
+	pid, err := peer.IDFromPublicKey(priv.GetPublic())
+	if err != nil {
+		return err
+	}
+
+	privPEM, authorizedKey, err := toOpenSSHKey(priv, peer.ToCid(pid).String())
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		if _, err := os.Stdout.Write(privPEM); err != nil {
+			return err
+		}
+		_, err = os.Stderr.Write(authorizedKey)
+		return err
+	}
+
+	if err := os.WriteFile(out, privPEM, 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(out+".pub", authorizedKey, 0644)
+}
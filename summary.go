@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// eolBuckets are the upper bounds of the time left until EOL that records are counted in, in order
+var eolBuckets = []struct {
+	label string
+	limit time.Duration
+}{
+	{"under 1h", time.Hour},
+	{"under 1d", 24 * time.Hour},
+	{"under 7d", 7 * 24 * time.Hour},
+	{"under 30d", 30 * 24 * time.Hour},
+}
+
+// seqnoSummary is the spread of sequence numbers across records
+type seqnoSummary struct {
+	Min    uint64
+	Median float64
+	Max    uint64
+}
+
+// eolSummary is the spread of EOLs across records. Buckets counts the unexpired records by time left until EOL.
+type eolSummary struct {
+	Earliest string
+	Latest   string
+	Buckets  map[string]int
+}
+
+// recordsSummary is the output of parse records --summary
+type recordsSummary struct {
+	Records        int
+	Expired        int
+	SequenceNumber *seqnoSummary `json:",omitempty"`
+	EOL            *eolSummary   `json:",omitempty"`
+	KeyTypes       map[string]int
+}
+
+// recordKeyType returns the type of the key that signed the record: the embedded public key's type,
+// or the type of the key inlined in the file name if it is an IPNS name. It is unknown otherwise, e.g. for RSA names.
+func recordKeyType(r fileRecord) string {
+	if r.Record.keyType != "" {
+		return r.Record.keyType
+	}
+	base := filepath.Base(r.File)
+	if i := strings.Index(base, "."); i > 0 {
+		base = base[:i]
+	}
+	if name, err := decodeIPNSName(base); err == nil {
+		if pub, err := name.ExtractPublicKey(); err == nil {
+			return pub.Type().String()
+		}
+	}
+	return "unknown"
+}
+
+// summarizeRecords aggregates the records, now decides which are expired
+func summarizeRecords(results []fileRecord, now time.Time) recordsSummary {
+	s := recordsSummary{Records: len(results), KeyTypes: map[string]int{}}
+	if len(results) == 0 {
+		return s
+	}
+
+	seqnos := make([]uint64, 0, len(results))
+	eol := &eolSummary{Buckets: map[string]int{}}
+	for _, b := range eolBuckets {
+		eol.Buckets[b.label] = 0
+	}
+	eol.Buckets["later"] = 0
+	var earliest, latest time.Time
+	for i, r := range results {
+		seqnos = append(seqnos, r.Record.SequenceNumber)
+		s.KeyTypes[recordKeyType(r)]++
+
+		t := r.Record.eol
+		if i == 0 || t.Before(earliest) {
+			earliest = t
+		}
+		if i == 0 || t.After(latest) {
+			latest = t
+		}
+		left := t.Sub(now)
+		if left <= 0 {
+			s.Expired++
+			continue
+		}
+		label := "later"
+		for _, b := range eolBuckets {
+			if left < b.limit {
+				label = b.label
+				break
+			}
+		}
+		eol.Buckets[label]++
+	}
+	eol.Earliest = earliest.UTC().Format(time.RFC3339)
+	eol.Latest = latest.UTC().Format(time.RFC3339)
+	s.EOL = eol
+
+	sort.Slice(seqnos, func(i, j int) bool { return seqnos[i] < seqnos[j] })
+	mid := len(seqnos) / 2
+	median := float64(seqnos[mid])
+	if len(seqnos)%2 == 0 {
+		median = (float64(seqnos[mid-1]) + float64(seqnos[mid])) / 2
+	}
+	s.SequenceNumber = &seqnoSummary{Min: seqnos[0], Median: median, Max: seqnos[len(seqnos)-1]}
+	return s
+}
+
+// printRecordsSummary prints the summary as JSON or, with table, as a table
+func printRecordsSummary(s recordsSummary, table, compact bool) error {
+	if !table {
+		return printJSON(s, compact)
+	}
+
+	rows := []tableRow{
+		{"records", fmt.Sprint(s.Records), colorNumber},
+		{"expired", fmt.Sprint(s.Expired), colorNumber},
+	}
+	if s.SequenceNumber != nil {
+		rows = append(rows, tableRow{"seqno min/median/max", fmt.Sprintf("%d / %g / %d", s.SequenceNumber.Min, s.SequenceNumber.Median, s.SequenceNumber.Max), colorNumber})
+	}
+	if s.EOL != nil {
+		rows = append(rows,
+			tableRow{"earliest EOL", s.EOL.Earliest, colorTimestamp},
+			tableRow{"latest EOL", s.EOL.Latest, colorTimestamp},
+		)
+		for _, b := range eolBuckets {
+			rows = append(rows, tableRow{"EOL " + b.label, fmt.Sprint(s.EOL.Buckets[b.label]), colorNumber})
+		}
+		rows = append(rows, tableRow{"EOL later", fmt.Sprint(s.EOL.Buckets["later"]), colorNumber})
+	}
+	var types []string
+	for t := range s.KeyTypes {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		rows = append(rows, tableRow{t + " keys", fmt.Sprint(s.KeyTypes[t]), colorNumber})
+	}
+	return writeKeyValueTable(os.Stdout, rows)
+}
+
+// outputFileRecords prints the records parsed by parse records, sorted by --sort, in the --format, or their --summary
+func outputFileRecords(results []fileRecord, c *cli.Context) error {
+	if c.Bool("summary") {
+		return printRecordsSummary(summarizeRecords(results, time.Now()), c.Bool("table"), c.Bool("compact"))
+	}
+	if err := sortFileRecords(results, c.String("sort")); err != nil {
+		return err
+	}
+	if c.String("format") == "csv" {
+		return writeRecordsCSV(results)
+	}
+	return printJSON(results, c.Bool("compact"))
+}
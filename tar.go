@@ -0,0 +1,74 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// maxTarEntrySize is the default bound on the tar entries read as records, anything bigger is far past the 10KiB record limit
+const maxTarEntrySize = 1 << 20
+
+// parseTarRecords parses every record in a tar archive, which may itself be gzip compressed according to decompress.
+// Entries that aren't regular files are ignored and entries that aren't records are skipped with a warning on stderr.
+// The records are sorted by entry name like the records of a directory.
+// Entries larger than limits.maxRecordSize are skipped without being read, as are entries that decompress to more than that,
+// and more than limits.maxRecords files is an error.
+func parseTarRecords(path, decompress, cidBase string, limits streamLimits) ([]fileRecord, error) {
+	archive, err := readInput(path, "path", decompress, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []fileRecord{}
+	tr := tar.NewReader(bytes.NewReader(archive))
+	read := 0
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read tar archive %s: %w", path, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			log.Debugw("ignoring tar entry that is not a file", "name", hdr.Name)
+			continue
+		}
+		read++
+		if err := limits.checkCount(read); err != nil {
+			return nil, err
+		}
+		if hdr.Size > limits.maxRecordSize {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s: larger than %d bytes, not a record\n", hdr.Name, limits.maxRecordSize)
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(tr, limits.maxRecordSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("could not read tar entry %s: %w", hdr.Name, err)
+		}
+		if int64(len(data)) > limits.maxRecordSize {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s: larger than %d bytes, not a record\n", hdr.Name, limits.maxRecordSize)
+			continue
+		}
+		if data, err = maybeDecompress(data, hdr.Name, decompress, limits.maxRecordSize); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s: %v\n", hdr.Name, err)
+			continue
+		}
+
+		rec, err := decodeIPNSRecord(data, cidBase)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s: not an IPNS record: %v\n", hdr.Name, err)
+			continue
+		}
+		results = append(results, fileRecord{File: hdr.Name, Record: rec, data: data})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].File < results[j].File })
+	return results, nil
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ipfs/go-ipns"
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// The fixed contents of every test vector record, only the key and record format vary
+const testVectorValue = "/ipfs/bafkqaaa"
+const testVectorSeqno = 1
+const testVectorTTL = time.Hour
+
+var testVectorEOL = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var testVectorKeyTypes = []string{"ed25519", "rsa", "ecdsa", "secp256k1"}
+
+type testVector struct {
+	Name         string
+	KeyType      string
+	Embedded     bool
+	Version      string
+	KeyFile      string
+	RecordFile   string
+	ExpectedFile string
+}
+
+// seededKey deterministically derives a key of the given type from r.
+// The standard library key generators may mix in extra randomness, so the keys are built from the raw bytes instead.
+func seededKey(keyType string, r io.Reader) (crypto.PrivKey, error) {
+	switch keyType {
+	case "ed25519":
+		priv, _, err := crypto.GenerateEd25519Key(r)
+		return priv, err
+	case "secp256k1":
+		d, err := seededScalar(r, secp256k1Order)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.UnmarshalSecp256k1PrivateKey(d.FillBytes(make([]byte, 32)))
+	case "ecdsa":
+		curve := elliptic.P256()
+		d, err := seededScalar(r, curve.Params().N)
+		if err != nil {
+			return nil, err
+		}
+		k := &ecdsa.PrivateKey{D: d}
+		k.Curve = curve
+		k.X, k.Y = curve.ScalarBaseMult(d.Bytes())
+		priv, _, err := crypto.ECDSAKeyPairFromKey(k)
+		return priv, err
+	case "rsa":
+		k, err := seededRSAKey(r, 2048)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.UnmarshalRsaPrivateKey(x509.MarshalPKCS1PrivateKey(k))
+	default:
+		return nil, crypto.ErrBadKeyType
+	}
+}
+
+var secp256k1Order, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+
+// seededScalar reads a scalar in [1, n) from r
+func seededScalar(r io.Reader, n *big.Int) (*big.Int, error) {
+	buf := make([]byte, (n.BitLen()+7)/8)
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		d := new(big.Int).SetBytes(buf)
+		if d.Sign() > 0 && d.Cmp(n) < 0 {
+			return d, nil
+		}
+	}
+}
+
+// seededPrime reads candidates from r until one is prime, ProbablyPrime is deterministic for a given candidate
+func seededPrime(r io.Reader, bits int) (*big.Int, error) {
+	buf := make([]byte, (bits+7)/8)
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		// set the top two bits so the product of two primes has the full length, and make it odd
+		buf[0] |= 0xc0
+		buf[len(buf)-1] |= 1
+		p := new(big.Int).SetBytes(buf)
+		if p.ProbablyPrime(20) {
+			return p, nil
+		}
+	}
+}
+
+func seededRSAKey(r io.Reader, bits int) (*rsa.PrivateKey, error) {
+	e := big.NewInt(65537)
+	one := big.NewInt(1)
+	for {
+		p, err := seededPrime(r, bits/2)
+		if err != nil {
+			return nil, err
+		}
+		q, err := seededPrime(r, bits/2)
+		if err != nil {
+			return nil, err
+		}
+		if p.Cmp(q) == 0 {
+			continue
+		}
+
+		pm1 := new(big.Int).Sub(p, one)
+		qm1 := new(big.Int).Sub(q, one)
+		phi := new(big.Int).Mul(pm1, qm1)
+		d := new(big.Int).ModInverse(e, phi)
+		if d == nil {
+			continue
+		}
+
+		k := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: new(big.Int).Mul(p, q), E: int(e.Int64())},
+			D:         d,
+			Primes:    []*big.Int{p, q},
+		}
+		if err := k.Validate(); err != nil {
+			return nil, err
+		}
+		k.Precompute()
+		return k, nil
+	}
+}
+
+// testVectorRecord creates the fixed test vector record signed by priv.
+// V1 records only have the V1 signature, V2 records have the CBOR data and both signatures as go-ipns creates them.
+func testVectorRecord(priv crypto.PrivKey, embedded bool, version string) (*ipns_pb.IpnsEntry, error) {
+	rec, err := ipns.Create(priv, []byte(testVectorValue), testVectorSeqno, testVectorEOL, testVectorTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch version {
+	case "v1":
+		rec.Data = nil
+		rec.SignatureV2 = nil
+	case "v2":
+	default:
+		return nil, fmt.Errorf("unknown record version %q", version)
+	}
+
+	// ipns.EmbedPublicKey skips keys that are inlined in the name, embed those anyway so both cases exist for every key type
+	if embedded {
+		pkBytes, err := crypto.MarshalPublicKey(priv.GetPublic())
+		if err != nil {
+			return nil, err
+		}
+		rec.PubKey = pkBytes
+	}
+	return rec, nil
+}
+
+// writeTestVectors writes a key per key type and records with and without embedded keys in V1 and V2 formats to dir,
+// along with the expected parse output of each record and an index.json describing them all.
+// Everything is reproducible from the seed except the ECDSA records, libp2p signs ECDSA with crypto/rand so their
+// signatures change on every run. Ed25519, RSA (PKCS #1 v1.5), and secp256k1 (RFC 6979) signatures are deterministic.
+func writeTestVectors(dir string, seed int64) error {
+	if dir == "" {
+		return errors.New("no output directory specified")
+	}
+	for _, sub := range []string{"keys", "records", "expected"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return err
+		}
+	}
+
+	r := rand.New(rand.NewSource(seed))
+
+	var vectors []testVector
+	for _, keyType := range testVectorKeyTypes {
+		priv, err := seededKey(keyType, r)
+		if err != nil {
+			return fmt.Errorf("could not create %s key: %w", keyType, err)
+		}
+
+		keyBytes, err := crypto.MarshalPrivateKey(priv)
+		if err != nil {
+			return err
+		}
+		keyFile := filepath.Join("keys", keyType+".key")
+		if err := os.WriteFile(filepath.Join(dir, keyFile), keyBytes, 0o600); err != nil {
+			return err
+		}
+
+		pid, err := peer.IDFromPrivateKey(priv)
+		if err != nil {
+			return err
+		}
+		name := peer.ToCid(pid).String()
+
+		for _, embedded := range []bool{false, true} {
+			for _, version := range []string{"v1", "v2"} {
+				rec, err := testVectorRecord(priv, embedded, version)
+				if err != nil {
+					return err
+				}
+				recBytes, err := rec.Marshal()
+				if err != nil {
+					return err
+				}
+
+				parsed, err := decodeIPNSRecord(recBytes, defaultCIDBase)
+				if err != nil {
+					return err
+				}
+
+				variant := "bare"
+				if embedded {
+					variant = "embedded"
+				}
+				// records are named after the IPNS name so that verify records can find the key for names that inline it
+				base := fmt.Sprintf("%s.%s-%s", name, variant, version)
+				v := testVector{
+					Name:         name,
+					KeyType:      keyType,
+					Embedded:     embedded,
+					Version:      version,
+					KeyFile:      keyFile,
+					RecordFile:   filepath.Join("records", base+".ipns-record"),
+					ExpectedFile: filepath.Join("expected", base+".json"),
+				}
+
+				if err := os.WriteFile(filepath.Join(dir, v.RecordFile), recBytes, 0o644); err != nil {
+					return err
+				}
+				if err := writeJSONFile(filepath.Join(dir, v.ExpectedFile), parsed); err != nil {
+					return err
+				}
+				vectors = append(vectors, v)
+			}
+		}
+	}
+
+	return writeJSONFile(filepath.Join(dir, "index.json"), vectors)
+}
+
+// writeJSONFile writes v to path formatted the same way as printJSON
+func writeJSONFile(path string, v interface{}) error {
+	out, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(out, '\n'), 0o644)
+}
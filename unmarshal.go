@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"unicode"
+
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+	"github.com/multiformats/go-multibase"
+)
+
+// unmarshalPreviewLen is how many leading bytes of a record that fails to unmarshal are shown
+const unmarshalPreviewLen = 16
+
+// unmarshalIPNSRecord unmarshals the record, and when that fails explains what the input looks like and how to fix it.
+// The protobuf error on its own rarely says more than that the bytes aren't a record.
+func unmarshalIPNSRecord(data []byte) (*ipns_pb.IpnsEntry, error) {
+	if len(data) == 0 {
+		return nil, errors.New("could not unmarshal IPNS record: the input is empty")
+	}
+	rec := &ipns_pb.IpnsEntry{}
+	err := rec.Unmarshal(data)
+	if err == nil {
+		return rec, nil
+	}
+
+	preview := data
+	if len(preview) > unmarshalPreviewLen {
+		preview = preview[:unmarshalPreviewLen]
+	}
+	return nil, fmt.Errorf("could not unmarshal IPNS record (%d bytes, starting %x): %w\nhint: %s", len(data), preview, err, unmarshalHint(data, err))
+}
+
+// unmarshalHint guesses why data isn't a record, e.g. text passed where raw bytes were expected
+func unmarshalHint(data []byte, err error) string {
+	if bytes.HasPrefix(data, gzipMagic) {
+		return "the input is gzip compressed, try --decompress gzip"
+	}
+	if bytes.HasPrefix(data, []byte("HTTP/")) {
+		return "the input looks like an HTTP response, try --http-response"
+	}
+	if !isText(data) {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return "the record ends in the middle of a field, it was probably truncated"
+		}
+		return "the input is binary but not an IPNS record, check it is the whole record and not some other file"
+	}
+
+	text := bytes.TrimSpace(data)
+	if bytes.HasPrefix(text, []byte("{")) || bytes.HasPrefix(text, []byte("[")) {
+		return "the input looks like JSON, e.g. a routing API response, try parse record --input-type routing-json"
+	}
+	if _, _, err := multibase.Decode(string(text)); err == nil {
+		return "the input looks like multibase encoded text, try --input-type multibase"
+	}
+	if _, err := base64.StdEncoding.DecodeString(string(text)); err == nil {
+		return "the input looks like base64 without a multibase prefix, add an m (or u for base64url) in front and use --input-type multibase"
+	}
+	return "the input looks like text rather than a binary record, if it is encoded try --input-type multibase"
+}
+
+// isText returns whether data is printable UTF-8 text
+func isText(data []byte) bool {
+	for _, r := range string(data) {
+		if r == unicode.ReplacementChar || (!unicode.IsPrint(r) && !unicode.IsSpace(r)) {
+			return false
+		}
+	}
+	return true
+}
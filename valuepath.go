@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multibase"
+)
+
+// defaultCIDBase is the base CIDs in record values are re-encoded to unless another one is chosen
+const defaultCIDBase = "base32"
+
+// valuePath is the value of a record split into its components.
+// CID is the root re-encoded to the chosen base, it is empty when the root is not a CID (e.g. a DNSLink name).
+// Error explains why the value isn't a valid content path, the other fields are filled in as far as parsing got.
+type valuePath struct {
+	Namespace string
+	Root      string
+	CID       string
+	Subpath   string
+	Error     string `json:",omitempty"`
+}
+
+// parseValuePath splits a record value like /ipfs/<cid>/a/b into its namespace, root CID, and subpath
+func parseValuePath(value, cidBase string) valuePath {
+	var p valuePath
+	if err := p.parse(value, cidBase); err != nil {
+		p.Error = err.Error()
+	}
+	return p
+}
+
+func (p *valuePath) parse(value, cidBase string) error {
+	enc, err := multibase.EncoderByName(cidBase)
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(value, "/") {
+		return fmt.Errorf("value %q is not a path", value)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, "/"), "/", 3)
+	p.Namespace = parts[0]
+	if len(parts) < 2 || parts[1] == "" {
+		return fmt.Errorf("value %q has no root after the namespace", value)
+	}
+	p.Root = parts[1]
+	if len(parts) == 3 {
+		p.Subpath = "/" + parts[2]
+	}
+
+	switch p.Namespace {
+	case "ipfs", "ipld":
+		c, err := cid.Decode(p.Root)
+		if err != nil {
+			return fmt.Errorf("root %q is not a CID: %w", p.Root, err)
+		}
+		p.CID, err = encodeCID(c, enc.Encoding())
+		return err
+	case "ipns":
+		if pid, err := decodeIPNSName(p.Root); err == nil {
+			p.CID, err = encodeCID(peer.ToCid(pid), enc.Encoding())
+			return err
+		}
+		if !strings.Contains(p.Root, ".") {
+			return fmt.Errorf("root %q is neither an IPNS name nor a DNSLink domain", p.Root)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown namespace %q, expected ipfs, ipns, or ipld", p.Namespace)
+	}
+}
+
+// encodeCID encodes the CID in the base, CIDv0s can only be base58btc so they are upgraded to CIDv1 for other bases
+func encodeCID(c cid.Cid, base multibase.Encoding) (string, error) {
+	if c.Version() == 0 && base != multibase.Base58BTC {
+		c = cid.NewCidV1(c.Type(), c.Hash())
+	}
+	return c.StringOfBase(base)
+}
+
+// valueFromAddOutput returns the /ipfs/ path of the CID printed by ipfs add.
+// It takes the last CID, which is the root, from either ipfs add -Q (just the CID) or the full "added <cid> <name>" lines.
+func valueFromAddOutput(r io.Reader) (string, error) {
+	var root string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) == 0:
+			continue
+		case len(fields) >= 2 && fields[0] == "added":
+			root = fields[1]
+		case len(fields) == 1:
+			root = fields[0]
+		default:
+			return "", fmt.Errorf("unexpected ipfs add output line %q", scanner.Text())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if root == "" {
+		return "", errors.New("no CID in the ipfs add output")
+	}
+
+	if _, err := cid.Decode(root); err != nil {
+		return "", fmt.Errorf("ipfs add output %q is not a CID: %w", root, err)
+	}
+	return "/ipfs/" + root, nil
+}
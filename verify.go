@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipns"
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// ipnsURIScheme is the scheme of ipns:// URIs, as used by browsers
+const ipnsURIScheme = "ipns://"
+
+// trimIPNSURI returns the name in an ipns://<name> URI, other strings are returned as they are
+func trimIPNSURI(name string) string {
+	if len(name) >= len(ipnsURIScheme) && strings.EqualFold(name[:len(ipnsURIScheme)], ipnsURIScheme) {
+		return strings.TrimSuffix(name[len(ipnsURIScheme):], "/")
+	}
+	return name
+}
+
+// decodeIPNSName returns the peer ID for the CIDv0, CIDv1, peer ID, or ipns:// URI representation of an IPNS name
+func decodeIPNSName(name string) (peer.ID, error) {
+	if trimmed := trimIPNSURI(name); trimmed != name {
+		if strings.Contains(trimmed, "/") {
+			return "", fmt.Errorf("%q has a path after the name, only the name is expected", name)
+		}
+		name = trimmed
+	}
+	c, err := cid.Decode(name)
+	if err != nil {
+		pid, pidErr := peer.Decode(name)
+		if pidErr != nil {
+			return "", fmt.Errorf("%q is neither a CID nor a peer ID: %w", name, err)
+		}
+		log.Debugw("decoded IPNS name as a peer ID", "name", name)
+		return pid, nil
+	}
+	if c.Version() == 1 && c.Type() != cid.Libp2pKey {
+		return "", fmt.Errorf("%s has the %s codec, IPNS names are libp2p-key CIDs", name, cid.CodecToStr[c.Type()])
+	}
+	log.Debugw("decoded IPNS name as a CID", "name", name, "version", c.Version())
+	return peer.IDFromBytes(c.Hash())
+}
+
+// checkEmbeddedPublicKey confirms that the public key embedded in the record, if any, belongs to the expected IPNS name
+func checkEmbeddedPublicKey(expected peer.ID, rec *ipns_pb.IpnsEntry) error {
+	if len(rec.PubKey) == 0 {
+		log.Debugw("record has no embedded public key", "name", peer.ToCid(expected))
+		return nil
+	}
+
+	pk, err := crypto.UnmarshalPublicKey(rec.PubKey)
+	if err != nil {
+		return fmt.Errorf("could not unmarshal the embedded public key: %w", err)
+	}
+
+	actual, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		return err
+	}
+
+	if actual != expected {
+		return fmt.Errorf("embedded public key does not match the IPNS name: expected %s, actual %s", peer.ToCid(expected), peer.ToCid(actual))
+	}
+	return nil
+}
+
+// verifyIPNSRecord checks that the record was signed by the key for the IPNS name and has not expired.
+// With acceptExpired an expired but authentic record is not an error, expired reports whether that happened.
+func verifyIPNSRecord(name peer.ID, rec *ipns_pb.IpnsEntry, acceptExpired bool) (expired bool, err error) {
+	if err := checkEmbeddedPublicKey(name, rec); err != nil {
+		return false, err
+	}
+
+	pk, err := ipns.ExtractPublicKey(name, rec)
+	if err != nil {
+		return false, err
+	}
+
+	log.Infow("validating record", "name", peer.ToCid(name), "signatureV2", rec.SignatureV2 != nil)
+	// go-ipns only checks the EOL once the signature is verified
+	err = ipns.Validate(pk, rec)
+	if acceptExpired && errors.Is(err, ipns.ErrExpiredRecord) {
+		log.Infow("ignoring expired record", "name", peer.ToCid(name))
+		return true, nil
+	}
+	return false, err
+}
+
+// nameForRecordFile returns the IPNS name a record file should be verified against.
+// This is the file name without extensions if it is an IPNS name, otherwise the name of the embedded public key.
+func nameForRecordFile(path string, rec *ipns_pb.IpnsEntry) (peer.ID, error) {
+	base := filepath.Base(path)
+	if i := strings.Index(base, "."); i > 0 {
+		base = base[:i]
+	}
+	if name, err := decodeIPNSName(base); err == nil {
+		return name, nil
+	}
+	log.Infow("file name is not an IPNS name, using the embedded public key", "path", path)
+
+	if len(rec.PubKey) == 0 {
+		return "", errors.New("file name is not an IPNS name and the record has no embedded public key")
+	}
+	pk, err := crypto.UnmarshalPublicKey(rec.PubKey)
+	if err != nil {
+		return "", fmt.Errorf("could not unmarshal the embedded public key: %w", err)
+	}
+	return peer.IDFromPublicKey(pk)
+}
+
+func verifyIPNSRecordFile(path, decompress string, acceptExpired bool) error {
+	rec, err := readIPNSRecordFile(path, decompress)
+	if err != nil {
+		return err
+	}
+
+	name, err := nameForRecordFile(path, rec)
+	if err != nil {
+		return err
+	}
+
+	expired, err := verifyIPNSRecord(name, rec, acceptExpired)
+	if err != nil {
+		return err
+	}
+	if expired {
+		fmt.Printf("%s: valid signature, expired (ignored with --accept-expired)\n", path)
+		return nil
+	}
+	fmt.Printf("%s: valid\n", path)
+	return nil
+}
+
+// checkMaxLifetime returns an error if the record's EOL is more than maxLifetime from now, which usually means the lifetime was mistyped
+func checkMaxLifetime(rec *ipns_pb.IpnsEntry, maxLifetime time.Duration) error {
+	eol, err := ipns.GetEOL(rec)
+	if err != nil {
+		return err
+	}
+
+	if lifetime := time.Until(eol); lifetime > maxLifetime {
+		return fmt.Errorf("record EOL %v is %v from now, which is more than the maximum lifetime of %v", eol, lifetime.Round(time.Second), maxLifetime)
+	}
+	return nil
+}
+
+// defaultMinLifetime is the lifetime below which create record warns by default, such short lifetimes are usually a typo
+const defaultMinLifetime = 10 * time.Minute
+
+// checkMinLifetime returns an error if the EOL is less than minLifetime from now, which makes the record expire before
+// it has been republished and resolutions start failing
+func checkMinLifetime(eol time.Time, minLifetime time.Duration) error {
+	lifetime := time.Until(eol)
+	if lifetime <= 0 {
+		return fmt.Errorf("the record EOL %v has already passed, the record will be expired when it's created", eol.UTC().Format(time.RFC3339))
+	}
+	if lifetime < minLifetime {
+		return fmt.Errorf("the record is only valid for %v, which is less than %v. Short lifetimes make resolution fail unless the record is republished before it expires", lifetime.Round(time.Second), minLifetime)
+	}
+	return nil
+}
+
+// batchVerification is the result of verifying one row of a verify batch CSV.
+// Result is pass, expired (only with --accept-expired), or fail, Reason explains failures.
+type batchVerification struct {
+	Name   string
+	Record string
+	Result string
+	Reason string
+}
+
+// readNameRecordCSV reads rows of an IPNS name and the path to its record file.
+// A header row starting with "name" is skipped, relative record paths are relative to the CSV file.
+func readNameRecordCSV(path string) ([][2]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+
+	var rows [][2]string
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 && strings.EqualFold(row[0], "name") {
+			continue
+		}
+
+		recordPath := row[1]
+		if !filepath.IsAbs(recordPath) {
+			recordPath = filepath.Join(filepath.Dir(path), recordPath)
+		}
+		rows = append(rows, [2]string{row[0], recordPath})
+	}
+}
+
+// verifyBatch verifies every record listed in the CSV against the IPNS name next to it and outputs the results as csv or json.
+// Every row is verified, an error is returned at the end if any of them failed.
+// Rows for names the allowlist doesn't allow are left out, and how many were is written to stderr.
+func verifyBatch(csvPath, format, decompress string, acceptExpired bool, allow nameAllowlist, compact bool) error {
+	if format != "csv" && format != "json" {
+		return fmt.Errorf("unknown output format %q, may be: csv or json", format)
+	}
+
+	rows, err := readNameRecordCSV(csvPath)
+	if err != nil {
+		return err
+	}
+
+	results := make([]batchVerification, 0, len(rows))
+	var failed, filtered int
+	for _, row := range rows {
+		if name, err := decodeIPNSName(row[0]); err == nil && !allow.allows(name) {
+			filtered++
+			continue
+		}
+		res := batchVerification{Name: row[0], Record: row[1], Result: "pass"}
+		if err := verifyNamedRecordFile(row[0], row[1], decompress, acceptExpired, &res); err != nil {
+			res.Result, res.Reason = "fail", err.Error()
+			failed++
+		}
+		results = append(results, res)
+	}
+
+	if format == "json" {
+		if err := printJSON(results, compact); err != nil {
+			return err
+		}
+	} else {
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"name", "record", "result", "reason"})
+		for _, res := range results {
+			w.Write([]string{res.Name, res.Record, res.Result, res.Reason})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+	}
+
+	if allow != nil {
+		fmt.Fprintf(os.Stderr, "filtered out %d records for names not in the names file\n", filtered)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d records failed", failed, len(results))
+	}
+	return nil
+}
+
+// verifyNamedRecordFile verifies the record file against the IPNS name, marking res as expired when that was ignored
+func verifyNamedRecordFile(ipnsName, path, decompress string, acceptExpired bool, res *batchVerification) error {
+	name, err := decodeIPNSName(ipnsName)
+	if err != nil {
+		return err
+	}
+	rec, err := readIPNSRecordFile(path, decompress)
+	if err != nil {
+		return err
+	}
+
+	expired, err := verifyIPNSRecord(name, rec, acceptExpired)
+	if err != nil {
+		return err
+	}
+	if expired {
+		res.Result, res.Reason = "expired", "valid signature, expired (ignored with --accept-expired)"
+	}
+	return nil
+}
+
+// verifyOwnership checks that the record at path was signed by the private key, i.e. that the key can republish it.
+// The record's embedded public key and, if the file is named after an IPNS name, the file name must also match the key's name.
+// An expired record is still owned by the key, that is only noted.
+func verifyOwnership(priv crypto.PrivKey, path, decompress string) error {
+	name, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		return err
+	}
+	rec, err := readIPNSRecordFile(path, decompress)
+	if err != nil {
+		return err
+	}
+
+	base := filepath.Base(path)
+	if i := strings.Index(base, "."); i > 0 {
+		base = base[:i]
+	}
+	if fileName, err := decodeIPNSName(base); err == nil && fileName != name {
+		return fmt.Errorf("the record file is named for %s but the key's name is %s", peer.ToCid(fileName), peer.ToCid(name))
+	}
+	if err := checkEmbeddedPublicKey(name, rec); err != nil {
+		return err
+	}
+
+	err = ipns.Validate(priv.GetPublic(), rec)
+	if errors.Is(err, ipns.ErrSignature) {
+		return fmt.Errorf("the record was not signed by the key for %s", peer.ToCid(name))
+	}
+	if errors.Is(err, ipns.ErrExpiredRecord) {
+		fmt.Printf("%s: owned by %s, expired\n", path, peer.ToCid(name))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: owned by %s\n", path, peer.ToCid(name))
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestDecodeIPNSName(t *testing.T) {
+	for _, s := range []string{"QmdygNbVyLEXiekrk6rYNi3hCaN7ZPNtr6BYQaJik2cKHY", "12D3KooWMQ8Nu7gnLsVdXMjjkQih9weUWszwQQ2MiiFXHWCbqT7h"} {
+		want, err := peer.Decode(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{s, peer.ToCid(want).String(), "ipns://" + s + "/"} {
+			got, err := decodeIPNSName(name)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if got != want {
+				t.Fatalf("%s decoded to %s, want %s", name, got, want)
+			}
+		}
+	}
+
+	for _, name := range []string{
+		// content CIDs, dag-pb and raw
+		"bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi",
+		"bafkqaaa",
+		"ipns://QmdygNbVyLEXiekrk6rYNi3hCaN7ZPNtr6BYQaJik2cKHY/path",
+		"not a name",
+	} {
+		if _, err := decodeIPNSName(name); err == nil {
+			t.Errorf("expected an error for %s", name)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multibase"
+)
+
+// maxPubSubMessageSize bounds a line of `ipfs pubsub sub --enc=json` output, records are at most 10KiB before encoding
+const maxPubSubMessageSize = 1 << 20
+
+// pubsubMessage is a message as output by `ipfs pubsub sub --enc=json`.
+// Newer Kubo versions multibase encode data and the topics, older ones base64 encode the data and the sender.
+type pubsubMessage struct {
+	From     string   `json:"from"`
+	Data     string   `json:"data"`
+	TopicIDs []string `json:"topicIDs"`
+}
+
+// validationFailure is the structured log line written for every message that doesn't hold a valid record
+type validationFailure struct {
+	Time   string
+	Level  string
+	Msg    string
+	Name   string  `json:",omitempty"`
+	Topic  string  `json:",omitempty"`
+	Peer   string  `json:",omitempty"`
+	Seqno  *uint64 `json:",omitempty"`
+	Reason string
+}
+
+// decodeMessageData decodes the data of a pubsub message, which is multibase or plain base64 depending on the Kubo version
+func decodeMessageData(s string) ([]byte, error) {
+	if _, data, err := multibase.Decode(s); err == nil {
+		return data, nil
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// decodeMessageTopic returns the topic as a string, decoding it if it is multibase encoded
+func decodeMessageTopic(s string) string {
+	if _, data, err := multibase.Decode(s); err == nil {
+		return string(data)
+	}
+	return s
+}
+
+// decodeMessagePeer returns the sender of a pubsub message, which is a peer ID string or its base64 encoded bytes
+func decodeMessagePeer(s string) string {
+	if pid, err := peer.Decode(s); err == nil {
+		return pid.String()
+	}
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+		if pid, err := peer.IDFromBytes(b); err == nil {
+			return pid.String()
+		}
+	}
+	return s
+}
+
+// watchPubSub validates the IPNS records in a stream of `ipfs pubsub sub --enc=json` messages until the stream ends.
+// Each message is checked against name, or the IPNS name of its topic when name is empty.
+// Failures are written to out as single line JSON for log pipelines, valid records are only logged.
+// Messages for names the allowlist doesn't allow are skipped, and how many were is written to stderr when the stream ends.
+func watchPubSub(r io.Reader, out io.Writer, name string, allow nameAllowlist) error {
+	var fixed peer.ID
+	if name != "" {
+		var err error
+		if fixed, err = decodeIPNSName(name); err != nil {
+			return err
+		}
+	}
+
+	var filtered int
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxPubSubMessageSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		failure, skipped := checkPubSubMessage(line, fixed, allow)
+		if skipped {
+			filtered++
+			continue
+		}
+		if failure == nil {
+			continue
+		}
+		failure.Time = time.Now().UTC().Format(time.RFC3339Nano)
+		failure.Level = "error"
+		failure.Msg = "record failed validation"
+		b, err := json.Marshal(failure)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(out, string(b)); err != nil {
+			return err
+		}
+	}
+	if allow != nil {
+		fmt.Fprintf(os.Stderr, "filtered out %d messages for names not in the names file\n", filtered)
+	}
+	return scanner.Err()
+}
+
+// topicIPNSName returns the IPNS name of an IPNS over PubSub topic, /record/<base64url of /ipns/<peer ID bytes>>
+func topicIPNSName(topic string) (peer.ID, error) {
+	if !strings.HasPrefix(topic, "/record/") {
+		return "", fmt.Errorf("%q does not start with /record/", topic)
+	}
+	key, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(topic, "/record/"))
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(string(key), "/ipns/") {
+		return "", fmt.Errorf("%q is not an IPNS record key", key)
+	}
+	return peer.IDFromBytes(key[len("/ipns/"):])
+}
+
+// checkPubSubMessage validates the record in a single message, returning nil if it is valid.
+// skipped is set instead when the message is for a name the allowlist doesn't allow.
+func checkPubSubMessage(line string, name peer.ID, allow nameAllowlist) (failure *validationFailure, skipped bool) {
+	var msg pubsubMessage
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		return &validationFailure{Reason: fmt.Sprintf("could not decode pubsub message: %v", err)}, false
+	}
+
+	failure = &validationFailure{Peer: decodeMessagePeer(msg.From)}
+	if len(msg.TopicIDs) > 0 {
+		failure.Topic = decodeMessageTopic(msg.TopicIDs[0])
+	}
+
+	if name == "" {
+		if failure.Topic == "" {
+			failure.Reason = "message has no topic to get the IPNS name from, pass it with --name"
+			return failure, false
+		}
+		var err error
+		if name, err = topicIPNSName(failure.Topic); err != nil {
+			failure.Reason = fmt.Sprintf("topic is not an IPNS topic: %v", err)
+			return failure, false
+		}
+	}
+	failure.Name = peer.ToCid(name).String()
+	if !allow.allows(name) {
+		log.Debugw("skipping message for a name not in the names file", "name", failure.Name)
+		return nil, true
+	}
+
+	data, err := decodeMessageData(msg.Data)
+	if err != nil {
+		failure.Reason = fmt.Sprintf("could not decode message data: %v", err)
+		return failure, false
+	}
+	rec := &ipns_pb.IpnsEntry{}
+	if err := rec.Unmarshal(data); err != nil {
+		failure.Reason = fmt.Sprintf("could not unmarshal record: %v", err)
+		return failure, false
+	}
+	seqno := rec.GetSequence()
+	failure.Seqno = &seqno
+
+	if _, err := verifyIPNSRecord(name, rec, false); err != nil {
+		failure.Reason = err.Error()
+		return failure, false
+	}
+	log.Infow("valid record", "name", failure.Name, "seqno", seqno, "peer", failure.Peer)
+	return nil, false
+}